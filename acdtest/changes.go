@@ -0,0 +1,95 @@
+package acdtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// defaultChunkSize is the number of nodes per changes object when the request
+// does not set one.
+const defaultChunkSize = 50
+
+type (
+	changesRequest struct {
+		Checkpoint    string `json:"checkpoint"`
+		ChunkSize     int    `json:"chunkSize"`
+		MaxNodes      int    `json:"maxNodes"`
+		IncludePurged string `json:"includePurged"`
+	}
+
+	changesResponse struct {
+		Checkpoint string      `json:"checkpoint"`
+		Nodes      []*fakeNode `json:"nodes"`
+		Reset      bool        `json:"reset"`
+		StatusCode int         `json:"statusCode"`
+	}
+)
+
+// handleChanges streams, one JSON object per line, all the nodes which have
+// changed since the checkpoint of the request. A missing or unknown checkpoint
+// resets the client with every node. The stream ends with {"end": true}.
+func (s *Server) handleChanges(w http.ResponseWriter, r *http.Request) {
+	var request changesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+	}
+	chunkSize := request.ChunkSize
+	if chunkSize < 1 {
+		chunkSize = defaultChunkSize
+	}
+	includePurged := request.IncludePurged == "true"
+
+	s.mutex.Lock()
+	since, err := strconv.ParseUint(request.Checkpoint, 10, 64)
+	reset := request.Checkpoint == "" || err != nil || since > s.seq
+	if reset {
+		since = 0
+	}
+	var changed []*fakeNode
+	for _, n := range s.nodes {
+		if n.seq <= since {
+			continue
+		}
+		if n.Status == statusPurged && (reset || !includePurged) {
+			continue
+		}
+		changed = append(changed, n)
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].seq < changed[j].seq })
+	if request.MaxNodes > 0 && len(changed) > request.MaxNodes {
+		changed = changed[:request.MaxNodes]
+	}
+
+	// encode while holding the lock, the nodes are mutated in place.
+	var lines [][]byte
+	for i := 0; i < len(changed) || i == 0; i += chunkSize {
+		end := min(i+chunkSize, len(changed))
+		chunk := changed[i:end]
+		checkpoint := s.seq
+		if end < len(changed) || request.MaxNodes > 0 {
+			if len(chunk) > 0 {
+				checkpoint = chunk[len(chunk)-1].seq
+			}
+		}
+		line, _ := json.Marshal(&changesResponse{
+			Checkpoint: strconv.FormatUint(checkpoint, 10),
+			Nodes:      chunk,
+			Reset:      reset,
+			StatusCode: http.StatusOK,
+		})
+		lines = append(lines, line)
+	}
+	s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	for _, line := range lines {
+		w.Write(append(line, '\n'))
+	}
+	w.Write([]byte(`{"end":true}` + "\n"))
+}
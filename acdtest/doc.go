@@ -0,0 +1,6 @@
+// Package acdtest provides an in-process fake of the Amazon Cloud Drive API
+// for hermetic tests. It implements the account, authentication, nodes
+// (folders, upload, overwrite, download, patch, children, children listing and
+// filtered listing), changes, trash, restore and bulk purge and restore
+// endpoints on top of net/http/httptest.
+package acdtest // import "github.com/montaguethomas/acd-go/acdtest"
@@ -0,0 +1,573 @@
+package acdtest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/montaguethomas/acd-go/node"
+)
+
+const (
+	kindFile   = "FILE"
+	kindFolder = "FOLDER"
+
+	statusAvailable = "AVAILABLE"
+	statusTrash     = "TRASH"
+	statusPurged    = "PURGED"
+
+	createdBy = "acdtest"
+)
+
+type (
+	// fakeNode is a node as stored by the fake.
+	fakeNode struct {
+		Id                string                       `json:"id"`
+		Name              string                       `json:"name,omitempty"`
+		Kind              string                       `json:"kind"`
+		Version           uint64                       `json:"version"`
+		ModifiedDate      time.Time                    `json:"modifiedDate"`
+		CreatedDate       time.Time                    `json:"createdDate"`
+		Labels            []string                     `json:"labels,omitempty"`
+		Description       string                       `json:"description,omitempty"`
+		CreatedBy         string                       `json:"createdBy"`
+		Parents           []string                     `json:"parents"`
+		Status            string                       `json:"status"`
+		Properties        map[string]map[string]string `json:"properties,omitempty"`
+		Restricted        bool                         `json:"restricted"`
+		IsRoot            bool                         `json:"isRoot,omitempty"`
+		ContentProperties *contentProperties           `json:"contentProperties,omitempty"`
+
+		content []byte
+		seq     uint64
+	}
+
+	contentProperties struct {
+		Version     uint64    `json:"version"`
+		Extension   string    `json:"extension,omitempty"`
+		Size        uint64    `json:"size"`
+		MD5         string    `json:"md5"`
+		ContentType string    `json:"contentType"`
+		ContentDate time.Time `json:"contentDate"`
+	}
+
+	// nodeMetadata is the request body for creating and patching nodes.
+	nodeMetadata struct {
+		Name        *string                      `json:"name"`
+		Kind        string                       `json:"kind"`
+		Labels      []string                     `json:"labels"`
+		Description *string                      `json:"description"`
+		Parents     []string                     `json:"parents"`
+		Properties  map[string]map[string]string `json:"properties"`
+	}
+)
+
+// newNode creates a new available node. The mutex must be held by the caller
+// (or the server not started yet).
+func (s *Server) newNode(name, kind string, parents []string) *fakeNode {
+	s.lastId++
+	now := s.clock().UTC()
+	n := &fakeNode{
+		Id:           fmt.Sprintf("acdtest%015d", s.lastId),
+		Name:         name,
+		Kind:         kind,
+		CreatedDate:  now,
+		ModifiedDate: now,
+		CreatedBy:    createdBy,
+		Parents:      append([]string{}, parents...),
+		Status:       statusAvailable,
+	}
+	s.nodes[n.Id] = n
+	s.touch(n)
+	return n
+}
+
+// touch records a change of the node. The mutex must be held by the caller.
+func (s *Server) touch(n *fakeNode) {
+	s.seq++
+	n.seq = s.seq
+	n.Version++
+	n.ModifiedDate = s.clock().UTC()
+}
+
+// setContent replaces the content of a file node. The mutex must be held by
+// the caller.
+func (s *Server) setContent(n *fakeNode, content []byte) {
+	sum := md5.Sum(content)
+	ext := strings.TrimPrefix(path.Ext(n.Name), ".")
+	contentType := "application/octet-stream"
+	if ct := mime.TypeByExtension(path.Ext(n.Name)); ct != "" {
+		contentType, _, _ = strings.Cut(ct, ";")
+	}
+	version := uint64(1)
+	if n.ContentProperties != nil {
+		version = n.ContentProperties.Version + 1
+	}
+	n.content = content
+	n.ContentProperties = &contentProperties{
+		Version:     version,
+		Extension:   ext,
+		Size:        uint64(len(content)),
+		MD5:         hex.EncodeToString(sum[:]),
+		ContentType: contentType,
+		ContentDate: s.clock().UTC(),
+	}
+	s.touch(n)
+}
+
+// childByName returns the available child of parentId named name. The mutex
+// must be held by the caller.
+func (s *Server) childByName(parentId, name string) *fakeNode {
+	for _, n := range s.nodes {
+		if n.Status != statusAvailable || !strings.EqualFold(n.Name, name) {
+			continue
+		}
+		for _, p := range n.Parents {
+			if p == parentId {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// children returns the available children of parentId sorted by name. The
+// mutex must be held by the caller.
+func (s *Server) children(parentId string) []*fakeNode {
+	var children []*fakeNode
+	for _, n := range s.nodes {
+		if n.Status != statusAvailable {
+			continue
+		}
+		for _, p := range n.Parents {
+			if p == parentId {
+				children = append(children, n)
+				break
+			}
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	return children
+}
+
+// lookup returns the available node at p. The mutex must be held by the
+// caller.
+func (s *Server) lookup(p string) *fakeNode {
+	n := s.nodes[s.rootId]
+	for _, part := range strings.Split(p, "/") {
+		if part == "" {
+			continue
+		}
+		if n = s.childByName(n.Id, part); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// mkdirAll returns the folder at p creating it and its parents as needed. The
+// mutex must be held by the caller.
+func (s *Server) mkdirAll(p string) (*fakeNode, error) {
+	n := s.nodes[s.rootId]
+	for _, part := range strings.Split(p, "/") {
+		if part == "" {
+			continue
+		}
+		child := s.childByName(n.Id, part)
+		if child == nil {
+			child = s.newNode(part, kindFolder, []string{n.Id})
+		}
+		if child.Kind != kindFolder {
+			return nil, fmt.Errorf("acdtest: %q is not a folder", part)
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// snapshot returns a copy of the node as the client would decode it.
+func (n *fakeNode) snapshot() *node.Node {
+	data, _ := json.Marshal(n)
+	var nn node.Node
+	json.Unmarshal(data, &nn)
+	return &nn
+}
+
+// RootId returns the Id of the root folder.
+func (s *Server) RootId() string {
+	return s.rootId
+}
+
+// MkdirAll creates the folder p and any missing parents and returns its Id.
+func (s *Server) MkdirAll(p string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, err := s.mkdirAll(p)
+	if err != nil {
+		return "", err
+	}
+	return n.Id, nil
+}
+
+// PutFile creates or overwrites the file p with content, creating any missing
+// parent folder, and returns its Id.
+func (s *Server) PutFile(p string, content []byte) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	parent, err := s.mkdirAll(path.Dir(path.Clean("/" + p)))
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(p)
+	n := s.childByName(parent.Id, name)
+	if n == nil {
+		n = s.newNode(name, kindFile, []string{parent.Id})
+	}
+	if n.Kind != kindFile {
+		return "", fmt.Errorf("acdtest: %q is not a file", p)
+	}
+	s.setContent(n, append([]byte{}, content...))
+	return n.Id, nil
+}
+
+// ReadFile returns the content of the file p.
+func (s *Server) ReadFile(p string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := s.lookup(p)
+	if n == nil || n.Kind != kindFile {
+		return nil, fmt.Errorf("acdtest: no such file %q", p)
+	}
+	return append([]byte{}, n.content...), nil
+}
+
+// Lookup returns a copy of the available node at p.
+func (s *Server) Lookup(p string) (*node.Node, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := s.lookup(p)
+	if n == nil {
+		return nil, false
+	}
+	return n.snapshot(), true
+}
+
+// Children returns a copy of the available children of the folder at p,
+// sorted by name, or false if there is no folder at p.
+func (s *Server) Children(p string) ([]*node.Node, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := s.lookup(p)
+	if n == nil || n.Kind != kindFolder {
+		return nil, false
+	}
+	var children []*node.Node
+	for _, child := range s.children(n.Id) {
+		children = append(children, child.snapshot())
+	}
+	return children, true
+}
+
+// Node returns a copy of the node identified by id, whatever its status.
+func (s *Server) Node(id string) (*node.Node, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok {
+		return nil, false
+	}
+	return n.snapshot(), true
+}
+
+// Trash moves the node at p to the trash.
+func (s *Server) Trash(p string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := s.lookup(p)
+	if n == nil || n.IsRoot {
+		return fmt.Errorf("acdtest: cannot trash %q", p)
+	}
+	n.Status = statusTrash
+	s.touch(n)
+	return nil
+}
+
+func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status == statusPurged {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+// handleCreateNode creates a folder (JSON body) or uploads a file (multipart
+// body).
+func (s *Server) handleCreateNode(w http.ResponseWriter, r *http.Request) {
+	var (
+		metadata nodeMetadata
+		content  []byte
+		upload   = strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/")
+	)
+	if upload {
+		var err error
+		var rawMetadata []byte
+		rawMetadata, content, err = readMultipart(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+		if err := json.Unmarshal(rawMetadata, &metadata); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+			return
+		}
+		if len(content) == 0 {
+			writeError(w, http.StatusBadRequest, "INVALID_INPUT", "empty content")
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+	if metadata.Name == nil || *metadata.Name == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", "name is required")
+		return
+	}
+	if (upload && metadata.Kind != kindFile) || (!upload && metadata.Kind != kindFolder) {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", fmt.Sprintf("invalid kind %q", metadata.Kind))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(metadata.Parents) == 0 {
+		metadata.Parents = []string{s.rootId}
+	}
+	for _, parentId := range metadata.Parents {
+		parent, ok := s.nodes[parentId]
+		if !ok || parent.Status != statusAvailable || parent.Kind != kindFolder {
+			writeError(w, http.StatusBadRequest, "INVALID_PARENT", fmt.Sprintf("invalid parent %s", parentId))
+			return
+		}
+		if existing := s.childByName(parentId, *metadata.Name); existing != nil {
+			writeNameConflict(w, existing)
+			return
+		}
+	}
+
+	n := s.newNode(*metadata.Name, metadata.Kind, metadata.Parents)
+	n.Labels = metadata.Labels
+	n.Properties = metadata.Properties
+	if metadata.Description != nil {
+		n.Description = *metadata.Description
+	}
+	if upload {
+		s.setContent(n, content)
+	}
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func (s *Server) handlePatchNode(w http.ResponseWriter, r *http.Request, id string) {
+	var metadata nodeMetadata
+	if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status == statusPurged {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	if metadata.Name != nil && *metadata.Name != "" && *metadata.Name != n.Name {
+		for _, parentId := range n.Parents {
+			if existing := s.childByName(parentId, *metadata.Name); existing != nil && existing != n {
+				writeNameConflict(w, existing)
+				return
+			}
+		}
+		n.Name = *metadata.Name
+	}
+	if metadata.Labels != nil {
+		n.Labels = metadata.Labels
+	}
+	if metadata.Description != nil {
+		n.Description = *metadata.Description
+	}
+	for owner, props := range metadata.Properties {
+		if n.Properties == nil {
+			n.Properties = map[string]map[string]string{}
+		}
+		if n.Properties[owner] == nil {
+			n.Properties[owner] = map[string]string{}
+		}
+		for key, value := range props {
+			n.Properties[owner][key] = value
+		}
+	}
+	s.touch(n)
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *Server) handleOverwrite(w http.ResponseWriter, r *http.Request, id string) {
+	_, content, err := readMultipart(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status != statusAvailable {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	if n.Kind != kindFile {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", "node is not a file")
+		return
+	}
+	s.setContent(n, content)
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request, id string) {
+	s.mutex.Lock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status == statusPurged {
+		s.mutex.Unlock()
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	if n.Kind != kindFile {
+		s.mutex.Unlock()
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", "node is not a file")
+		return
+	}
+	name, modified, content := n.Name, n.ModifiedDate, n.content
+	s.mutex.Unlock()
+
+	// ServeContent takes care of the Range requests.
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, name, modified, bytes.NewReader(content))
+}
+
+// handleAddChild adds the parent to the parents of the child. A folder cannot
+// be added under itself or one of its descendants.
+func (s *Server) handleAddChild(w http.ResponseWriter, r *http.Request, parentId, childId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	parent, ok := s.nodes[parentId]
+	if !ok || parent.Status != statusAvailable || parent.Kind != kindFolder {
+		writeError(w, http.StatusBadRequest, "INVALID_PARENT", fmt.Sprintf("invalid parent %s", parentId))
+		return
+	}
+	child, ok := s.nodes[childId]
+	if !ok || child.Status != statusAvailable {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", childId))
+		return
+	}
+	if slices.Contains(child.Parents, parentId) {
+		writeJSON(w, http.StatusOK, child)
+		return
+	}
+	if s.isAncestor(child, parent) {
+		writeError(w, http.StatusBadRequest, "INVALID_PARENT", fmt.Sprintf("%s is a descendant of %s", parentId, childId))
+		return
+	}
+	if existing := s.childByName(parentId, child.Name); existing != nil {
+		writeNameConflict(w, existing)
+		return
+	}
+	child.Parents = append(child.Parents, parentId)
+	s.touch(child)
+	writeJSON(w, http.StatusOK, child)
+}
+
+// handleRemoveChild removes the parent from the parents of the child, which
+// must keep at least one parent.
+func (s *Server) handleRemoveChild(w http.ResponseWriter, r *http.Request, parentId, childId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	child, ok := s.nodes[childId]
+	if !ok || child.Status != statusAvailable || !slices.Contains(child.Parents, parentId) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found under %s", childId, parentId))
+		return
+	}
+	if len(child.Parents) == 1 {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", fmt.Sprintf("node %s must have a parent", childId))
+		return
+	}
+	child.Parents = slices.DeleteFunc(child.Parents, func(id string) bool { return id == parentId })
+	s.touch(child)
+	writeJSON(w, http.StatusOK, child)
+}
+
+// isAncestor returns whether n is the node or one of the ancestors of
+// descendant. The mutex must be held by the caller.
+func (s *Server) isAncestor(n, descendant *fakeNode) bool {
+	if n == descendant {
+		return true
+	}
+	for _, parentId := range descendant.Parents {
+		if parent, ok := s.nodes[parentId]; ok && s.isAncestor(n, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// readMultipart returns the metadata field and the content file of a
+// multipart upload.
+func readMultipart(r *http.Request) (metadata, content []byte, err error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch part.FormName() {
+		case "metadata":
+			metadata = data
+		case "content":
+			content = data
+		}
+	}
+	if content == nil {
+		return nil, nil, fmt.Errorf("missing content")
+	}
+	return metadata, content, nil
+}
+
+func writeNameConflict(w http.ResponseWriter, existing *fakeNode) {
+	writeJSON(w, http.StatusConflict, map[string]interface{}{
+		"code":    "NAME_ALREADY_EXISTS",
+		"message": fmt.Sprintf("Node with the name %s already exists under the parent", existing.Name),
+		"logref":  "acdtest-logref",
+		"info":    map[string]string{"nodeId": existing.Id},
+	})
+}
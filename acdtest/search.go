@@ -0,0 +1,367 @@
+package acdtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNodesLimit is the page size of the nodes listing when the request
+// does not set one.
+const defaultNodesLimit = 200
+
+// searchFilter reports whether a node is selected by a filters expression.
+type searchFilter func(n *fakeNode) bool
+
+// handleListNodes lists the nodes which are not purged and are selected by
+// the filters parameter, in the query language of the nodes endpoint: terms
+// field:value, field:prefix* and field:[lower TO upper] joined by AND and OR,
+// AND binding tighter, and grouped in parentheses.
+func (s *Server) handleListNodes(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSearchFilter(r.URL.Query().Get("filters"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var selected []*fakeNode
+	for _, n := range s.nodes {
+		if n.Status != statusPurged && filter(n) {
+			selected = append(selected, n)
+		}
+	}
+	writeNodesPage(w, r, selected, defaultNodesLimit)
+}
+
+// handleListChildren lists the available children of the folder.
+func (s *Server) handleListChildren(w http.ResponseWriter, r *http.Request, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status == statusPurged {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	writeNodesPage(w, r, s.children(id), defaultNodesLimit)
+}
+
+// writeNodesPage writes the page of the nodes selected by the limit and the
+// startToken of the request, the nodes being sorted by Id. The nextToken is
+// the offset of the next page. The mutex must be held by the caller.
+func writeNodesPage(w http.ResponseWriter, r *http.Request, nodes []*fakeNode, defaultLimit int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultLimit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("startToken"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id < nodes[j].Id })
+
+	response := map[string]interface{}{
+		"count": len(nodes),
+	}
+	offset = min(offset, len(nodes))
+	end := min(offset+limit, len(nodes))
+	page := nodes[offset:end]
+	if page == nil {
+		page = []*fakeNode{}
+	}
+	response["data"] = page
+	if end < len(nodes) {
+		response["nextToken"] = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parseSearchFilter parses a filters expression, an empty one selecting every
+// node.
+func parseSearchFilter(expr string) (searchFilter, error) {
+	tokens, err := tokenizeSearchFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return func(*fakeNode) bool { return true }, nil
+	}
+	p := &searchParser{tokens: tokens}
+	filter, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filters", p.tokens[p.pos])
+	}
+	return filter, nil
+}
+
+// tokenizeSearchFilter splits the expression into parentheses, operators and
+// terms, keeping the escapes of the terms.
+func tokenizeSearchFilter(expr string) ([]string, error) {
+	var tokens []string
+	var term strings.Builder
+	inRange := false
+	flush := func() {
+		if term.Len() > 0 {
+			tokens = append(tokens, term.String())
+			term.Reset()
+		}
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == '\\':
+			if i+1 == len(expr) {
+				return nil, fmt.Errorf("dangling escape in filters")
+			}
+			term.WriteByte(c)
+			term.WriteByte(expr[i+1])
+			i++
+		case inRange:
+			term.WriteByte(c)
+			inRange = c != ']'
+		case c == '[':
+			term.WriteByte(c)
+			inRange = true
+		case c == ' ':
+			flush()
+		case c == '(' && term.Len() == 0, c == ')':
+			flush()
+			tokens = append(tokens, string(c))
+		default:
+			term.WriteByte(c)
+		}
+	}
+	if inRange {
+		return nil, fmt.Errorf("unterminated range in filters")
+	}
+	flush()
+	return tokens, nil
+}
+
+type searchParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *searchParser) next(token string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == token {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *searchParser) or() (searchFilter, error) {
+	return p.join("OR", p.and, func(a, b searchFilter) searchFilter {
+		return func(n *fakeNode) bool { return a(n) || b(n) }
+	})
+}
+
+func (p *searchParser) and() (searchFilter, error) {
+	return p.join("AND", p.operand, func(a, b searchFilter) searchFilter {
+		return func(n *fakeNode) bool { return a(n) && b(n) }
+	})
+}
+
+// join parses operands separated by the operator op.
+func (p *searchParser) join(op string, operand func() (searchFilter, error), combine func(a, b searchFilter) searchFilter) (searchFilter, error) {
+	filter, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.next(op) {
+		other, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		filter = combine(filter, other)
+	}
+	return filter, nil
+}
+
+func (p *searchParser) operand() (searchFilter, error) {
+	if p.next("(") {
+		filter, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.next(")") {
+			return nil, fmt.Errorf("missing ) in filters")
+		}
+		return filter, nil
+	}
+	if p.pos == len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of filters")
+	}
+	token := p.tokens[p.pos]
+	if token == ")" || token == "AND" || token == "OR" {
+		return nil, fmt.Errorf("unexpected %q in filters", token)
+	}
+	p.pos++
+	return parseSearchTerm(token)
+}
+
+// parseSearchTerm parses a term field:value, field:prefix* or
+// field:[lower TO upper].
+func parseSearchTerm(term string) (searchFilter, error) {
+	field, value, ok := cutUnescaped(term, ':')
+	if !ok {
+		return nil, fmt.Errorf("invalid term %q in filters", term)
+	}
+	values, ok := searchFields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q in filters", field)
+	}
+
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		lower, upper, ok := strings.Cut(value[1:len(value)-1], " TO ")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q in filters", value)
+		}
+		inRange, err := parseSearchRange(field, strings.TrimSpace(lower), strings.TrimSpace(upper))
+		if err != nil {
+			return nil, err
+		}
+		return func(n *fakeNode) bool {
+			for _, v := range values(n) {
+				if inRange(v) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	prefix := strings.HasSuffix(value, "*") && !strings.HasSuffix(value, `\*`)
+	if prefix {
+		value = value[:len(value)-1]
+	}
+	value = unescapeSearchValue(value)
+	return func(n *fakeNode) bool {
+		for _, v := range values(n) {
+			if prefix && len(v) >= len(value) && strings.EqualFold(v[:len(value)], value) ||
+				!prefix && strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseSearchRange returns whether a value of the field is in the range, the
+// bounds being included and "*" leaving the range open.
+func parseSearchRange(field, lower, upper string) (func(string) bool, error) {
+	if _, ok := searchDateFields[field]; ok {
+		var after, before time.Time
+		var err error
+		if lower != "*" {
+			if after, err = time.Parse(time.RFC3339, lower); err != nil {
+				return nil, fmt.Errorf("invalid date %q in filters", lower)
+			}
+		}
+		if upper != "*" {
+			if before, err = time.Parse(time.RFC3339, upper); err != nil {
+				return nil, fmt.Errorf("invalid date %q in filters", upper)
+			}
+		}
+		return func(v string) bool {
+			t, err := time.Parse(time.RFC3339, v)
+			return err == nil && (after.IsZero() || !t.Before(after)) && (before.IsZero() || !t.After(before))
+		}, nil
+	}
+
+	var min, max uint64 = 0, ^uint64(0)
+	var err error
+	if lower != "*" {
+		if min, err = strconv.ParseUint(lower, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %q in filters", lower)
+		}
+	}
+	if upper != "*" {
+		if max, err = strconv.ParseUint(upper, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid number %q in filters", upper)
+		}
+	}
+	return func(v string) bool {
+		i, err := strconv.ParseUint(v, 10, 64)
+		return err == nil && i >= min && i <= max
+	}, nil
+}
+
+// cutUnescaped cuts s around the first unescaped sep.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func unescapeSearchValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// searchDateFields are the fields compared as dates in the ranges, the other
+// fields of the ranges being compared as numbers.
+var searchDateFields = map[string]bool{
+	"createdDate":                   true,
+	"modifiedDate":                  true,
+	"contentProperties.contentDate": true,
+}
+
+// searchFields return the values of the fields of a node which can be
+// filtered on.
+var searchFields = map[string]func(n *fakeNode) []string{
+	"kind":        func(n *fakeNode) []string { return []string{n.Kind} },
+	"name":        func(n *fakeNode) []string { return []string{n.Name} },
+	"status":      func(n *fakeNode) []string { return []string{n.Status} },
+	"description": func(n *fakeNode) []string { return []string{n.Description} },
+	"labels":      func(n *fakeNode) []string { return n.Labels },
+	"parents":     func(n *fakeNode) []string { return n.Parents },
+	"isRoot":      func(n *fakeNode) []string { return []string{strconv.FormatBool(n.IsRoot)} },
+	"createdDate": func(n *fakeNode) []string { return []string{n.CreatedDate.Format(time.RFC3339Nano)} },
+	"modifiedDate": func(n *fakeNode) []string {
+		return []string{n.ModifiedDate.Format(time.RFC3339Nano)}
+	},
+	"contentProperties.contentType": contentField(func(cp *contentProperties) string { return cp.ContentType }),
+	"contentProperties.extension":   contentField(func(cp *contentProperties) string { return cp.Extension }),
+	"contentProperties.md5":         contentField(func(cp *contentProperties) string { return cp.MD5 }),
+	"contentProperties.size": contentField(func(cp *contentProperties) string {
+		return strconv.FormatUint(cp.Size, 10)
+	}),
+	"contentProperties.version": contentField(func(cp *contentProperties) string {
+		return strconv.FormatUint(cp.Version, 10)
+	}),
+	"contentProperties.contentDate": contentField(func(cp *contentProperties) string {
+		return cp.ContentDate.Format(time.RFC3339Nano)
+	}),
+}
+
+// contentField returns the value of a content property, none for a node
+// without content.
+func contentField(value func(cp *contentProperties) string) func(n *fakeNode) []string {
+	return func(n *fakeNode) []string {
+		if n.ContentProperties == nil {
+			return nil
+		}
+		return []string{value(n.ContentProperties)}
+	}
+}
@@ -0,0 +1,355 @@
+package acdtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+const (
+	// MetadataPath is the path of the metadata endpoint of the fake.
+	MetadataPath = "/drive/v1/"
+	// ContentPath is the path of the content endpoint of the fake.
+	ContentPath = "/cdproxy/"
+	// EndpointPath is the path used to discover the endpoints.
+	EndpointPath = "/drive/v1/account/endpoint"
+	// TokenPath is the path used to exchange a refresh token.
+	TokenPath = "/auth/token"
+)
+
+// Server is a fake Amazon Cloud Drive. It must be created with NewServer and
+// closed with Close. All of its methods are safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the fake, of the form http://ipaddr:port with no
+	// trailing slash.
+	URL string
+
+	// RefreshToken is the only refresh token accepted by the token endpoint.
+	// Once a token has been exchanged, all the requests must carry one of the
+	// issued access tokens.
+	RefreshToken string
+
+	server *httptest.Server
+
+	mutex        sync.Mutex
+	accessTokens map[string]bool
+	clock        func() time.Time
+	failures     []failure
+	lastId       uint64
+	nodes        map[string]*fakeNode
+	requests     int
+	rootId       string
+	seq          uint64
+}
+
+type failure struct {
+	status int
+	count  int
+}
+
+// NewServer starts and returns a new fake with an empty drive.
+func NewServer() *Server {
+	s := &Server{
+		RefreshToken: "acdtest-refresh-token",
+		accessTokens: make(map[string]bool),
+		clock:        time.Now,
+		nodes:        make(map[string]*fakeNode),
+	}
+	root := s.newNode("", kindFolder, nil)
+	root.IsRoot = true
+	s.rootId = root.Id
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts down the fake.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// EndpointURL returns the URL of the endpoint discovery of the fake.
+func (s *Server) EndpointURL() string {
+	return s.URL + EndpointPath
+}
+
+// TokenURL returns the URL of the token endpoint of the fake.
+func (s *Server) TokenURL() string {
+	return s.URL + TokenPath
+}
+
+// Client returns an HTTP client sending all the requests for the Amazon
+// hosts (constants.AmazonDriveEndpointURL and constants.AmazonAPITokenURL)
+// to the fake.
+func (s *Server) Client() *http.Client {
+	return &http.Client{
+		Transport: s.Transport(),
+	}
+}
+
+// Transport returns an http.RoundTripper sending all the requests for the
+// Amazon hosts to the fake.
+func (s *Server) Transport() http.RoundTripper {
+	target, _ := url.Parse(s.URL)
+	hosts := map[string]bool{}
+	for _, u := range []string{constants.AmazonDriveEndpointURL, constants.AmazonAPITokenURL} {
+		if pu, err := url.Parse(u); err == nil {
+			hosts[pu.Host] = true
+		}
+	}
+	return &rewriteTransport{
+		base:   s.server.Client().Transport,
+		hosts:  hosts,
+		target: target,
+	}
+}
+
+// SetClock replaces the clock used for the dates of the nodes.
+func (s *Server) SetClock(clock func() time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock = clock
+}
+
+// FailNext makes the next count requests fail with the HTTP status.
+func (s *Server) FailNext(status, count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, failure{status: status, count: count})
+}
+
+// Requests returns the number of requests served so far.
+func (s *Server) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	if len(s.failures) > 0 {
+		f := &s.failures[0]
+		f.count--
+		if f.count <= 0 {
+			s.failures = s.failures[1:]
+		}
+		s.mutex.Unlock()
+		writeError(w, f.status, "INJECTED_FAILURE", "failure injected by acdtest")
+		return
+	}
+	s.mutex.Unlock()
+
+	if r.URL.Path == TokenPath {
+		s.handleToken(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid or missing access token")
+		return
+	}
+
+	var p string
+	switch {
+	case strings.HasPrefix(r.URL.Path, MetadataPath):
+		p = strings.TrimPrefix(r.URL.Path, MetadataPath)
+	case strings.HasPrefix(r.URL.Path, ContentPath):
+		p = strings.TrimPrefix(r.URL.Path, ContentPath)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "no such endpoint")
+		return
+	}
+	var parts []string
+	for _, part := range strings.Split(p, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	s.route(w, r, parts)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request, parts []string) {
+	route := strings.Join(parts, "/")
+	switch {
+	case r.Method == "GET" && route == "account/endpoint":
+		s.handleEndpoint(w, r)
+	case r.Method == "GET" && route == "account/info":
+		s.handleAccountInfo(w, r)
+	case r.Method == "GET" && route == "account/quota":
+		s.handleAccountQuota(w, r)
+	case r.Method == "GET" && route == "account/usage":
+		s.handleAccountUsage(w, r)
+	case r.Method == "POST" && route == "changes":
+		s.handleChanges(w, r)
+	case r.Method == "GET" && route == "nodes":
+		s.handleListNodes(w, r)
+	case r.Method == "POST" && route == "nodes":
+		s.handleCreateNode(w, r)
+	case r.Method == "GET" && len(parts) == 2 && parts[0] == "nodes":
+		s.handleGetNode(w, r, parts[1])
+	case r.Method == "PATCH" && len(parts) == 2 && parts[0] == "nodes":
+		s.handlePatchNode(w, r, parts[1])
+	case r.Method == "GET" && len(parts) == 3 && parts[0] == "nodes" && parts[2] == "content":
+		s.handleDownload(w, r, parts[1])
+	case r.Method == "PUT" && len(parts) == 3 && parts[0] == "nodes" && parts[2] == "content":
+		s.handleOverwrite(w, r, parts[1])
+	case r.Method == "GET" && len(parts) == 3 && parts[0] == "nodes" && parts[2] == "children":
+		s.handleListChildren(w, r, parts[1])
+	case r.Method == "PUT" && len(parts) == 4 && parts[0] == "nodes" && parts[2] == "children":
+		s.handleAddChild(w, r, parts[1], parts[3])
+	case r.Method == "DELETE" && len(parts) == 4 && parts[0] == "nodes" && parts[2] == "children":
+		s.handleRemoveChild(w, r, parts[1], parts[3])
+	case r.Method == "GET" && route == "trash":
+		s.handleListTrash(w, r)
+	case r.Method == "PUT" && len(parts) == 2 && parts[0] == "trash":
+		s.handleTrash(w, r, parts[1])
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "trash" && parts[2] == "restore":
+		s.handleRestore(w, r, parts[1])
+	case r.Method == "POST" && route == "bulk/nodes/purge":
+		s.handleBulkPurge(w, r)
+	case r.Method == "POST" && route == "bulk/nodes/restore":
+		s.handleBulkRestore(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("no such endpoint %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// authorized returns true if the request carries an issued access token, or
+// if no token has been issued yet.
+func (s *Server) authorized(r *http.Request) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.accessTokens) == 0 || s.accessTokens[r.Header.Get("x-amz-access-token")]
+}
+
+func (s *Server) handleEndpoint(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"contentUrl":      s.URL + ContentPath,
+		"countryAtSignup": "USA",
+		"customerExists":  true,
+		"metadataUrl":     s.URL + MetadataPath,
+		"region":          "NA",
+	})
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SourceToken     string `json:"source_token"`
+		SourceTokenType string `json:"source_token_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if request.SourceTokenType != "refresh_token" || request.SourceToken != s.RefreshToken {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "The request has an invalid grant parameter : refresh_token",
+			"request_id":        "acdtest-token-request",
+		})
+		return
+	}
+
+	s.mutex.Lock()
+	s.lastId++
+	token := fmt.Sprintf("acdtest-access-token-%d", s.lastId)
+	s.accessTokens[token] = true
+	s.mutex.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"expires_in":   3600,
+		"token_type":   "bearer",
+	})
+}
+
+func (s *Server) handleAccountInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"termsOfUse": "1.0.0",
+		"status":     "ACTIVE",
+	})
+}
+
+func (s *Server) handleAccountQuota(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	const quota = 1 << 40
+	used := s.usedBytes()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"quota":          quota,
+		"lastCalculated": s.clock().UTC(),
+		"available":      quota - used,
+	})
+}
+
+func (s *Server) handleAccountUsage(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var count uint64
+	for _, n := range s.nodes {
+		if n.Kind == kindFile && n.Status != statusPurged {
+			count++
+		}
+	}
+	usage := map[string]uint64{"bytes": s.usedBytes(), "count": count}
+	category := map[string]interface{}{"billable": usage, "total": usage}
+	empty := map[string]interface{}{"billable": map[string]uint64{}, "total": map[string]uint64{}}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"lastCalculated": s.clock().UTC(),
+		"doc":            category,
+		"other":          empty,
+		"photo":          empty,
+		"video":          empty,
+	})
+}
+
+// usedBytes returns the size of all the files which are not purged. The mutex
+// must be held by the caller.
+func (s *Server) usedBytes() uint64 {
+	var used uint64
+	for _, n := range s.nodes {
+		if n.Kind == kindFile && n.Status != statusPurged {
+			used += uint64(len(n.content))
+		}
+	}
+	return used
+}
+
+// rewriteTransport sends the requests for hosts to target.
+type rewriteTransport struct {
+	base   http.RoundTripper
+	hosts  map[string]bool
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.hosts[r.URL.Host] {
+		r = r.Clone(r.Context())
+		r.URL.Scheme = t.target.Scheme
+		r.URL.Host = t.target.Host
+		r.Host = ""
+	}
+	return t.base.RoundTrip(r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-amzn-RequestId", "acdtest-request")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{
+		"code":    code,
+		"message": message,
+		"logref":  "acdtest-logref",
+	})
+}
@@ -0,0 +1,168 @@
+package acdtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// defaultTrashLimit is the page size of the trash listing when the request
+// does not set one.
+const defaultTrashLimit = 200
+
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status == statusPurged {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	if n.IsRoot {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", "the root cannot be trashed")
+		return
+	}
+	if n.Status != statusTrash {
+		n.Status = statusTrash
+		s.touch(n)
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+// handleListTrash lists the nodes in the trash.
+func (s *Server) handleListTrash(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var trashed []*fakeNode
+	for _, n := range s.nodes {
+		if n.Status == statusTrash {
+			trashed = append(trashed, n)
+		}
+	}
+	writeNodesPage(w, r, trashed, defaultTrashLimit)
+}
+
+// handleBulkPurge purges the nodes, which must be in the trash. The errorMap
+// of the response holds the status code of the nodes which failed.
+func (s *Server) handleBulkPurge(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Recurse string   `json:"recurse"`
+		NodeIds []string `json:"nodeIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+	if len(request.NodeIds) > 50 {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", "nodeIds must have length less than or equal to 50")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	errorMap := map[string]int{}
+	for _, id := range request.NodeIds {
+		n, ok := s.nodes[id]
+		switch {
+		case !ok || n.Status == statusPurged:
+			errorMap[id] = http.StatusNotFound
+		case n.Status != statusTrash:
+			errorMap[id] = http.StatusBadRequest
+		default:
+			s.purge(n, request.Recurse == "true")
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errorMap": errorMap,
+	})
+}
+
+// handleRestore restores the node from the trash.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n, ok := s.nodes[id]
+	if !ok || n.Status == statusPurged {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("node %s not found", id))
+		return
+	}
+	if n.Status == statusTrash {
+		if existing := s.restoreConflict(n); existing != nil {
+			writeNameConflict(w, existing)
+			return
+		}
+		n.Status = statusAvailable
+		s.touch(n)
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+// handleBulkRestore restores the nodes, which must be in the trash. The
+// errorMap of the response holds the status code of the nodes which failed.
+func (s *Server) handleBulkRestore(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		NodeIds []string `json:"nodeIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", err.Error())
+		return
+	}
+	if len(request.NodeIds) > 50 {
+		writeError(w, http.StatusBadRequest, "INVALID_INPUT", "nodeIds must have length less than or equal to 50")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	errorMap := map[string]int{}
+	for _, id := range request.NodeIds {
+		n, ok := s.nodes[id]
+		switch {
+		case !ok || n.Status == statusPurged:
+			errorMap[id] = http.StatusNotFound
+		case n.Status != statusTrash:
+			errorMap[id] = http.StatusBadRequest
+		case s.restoreConflict(n) != nil:
+			errorMap[id] = http.StatusConflict
+		default:
+			n.Status = statusAvailable
+			s.touch(n)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errorMap": errorMap,
+	})
+}
+
+// restoreConflict returns the available node which has the name of n in one
+// of its parents, if any. The mutex must be held by the caller.
+func (s *Server) restoreConflict(n *fakeNode) *fakeNode {
+	for _, parentId := range n.Parents {
+		if existing := s.childByName(parentId, n.Name); existing != nil {
+			return existing
+		}
+	}
+	return nil
+}
+
+// purge marks the node, and its descendants if recurse is true, as purged.
+// The mutex must be held by the caller.
+func (s *Server) purge(n *fakeNode, recurse bool) {
+	n.Status = statusPurged
+	n.content = nil
+	s.touch(n)
+	if !recurse || n.Kind != kindFolder {
+		return
+	}
+	for _, child := range s.nodes {
+		if child.Status == statusPurged {
+			continue
+		}
+		for _, parentId := range child.Parents {
+			if parentId == n.Id {
+				s.purge(child, recurse)
+				break
+			}
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -99,8 +101,13 @@ func (au *AccountUsage) Total() (bytes uint64, count uint64) {
 
 // GetAccountInfo returns AccountInfo about the current account.
 func (c *Client) GetAccountInfo() (*AccountInfo, error) {
+	return c.GetAccountInfoContext(context.Background())
+}
+
+// GetAccountInfoContext is like GetAccountInfo but uses ctx for the request.
+func (c *Client) GetAccountInfoContext(ctx context.Context) (*AccountInfo, error) {
 	var ai AccountInfo
-	req, err := http.NewRequest("GET", c.GetMetadataURL("account/info"), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.GetMetadataURL("account/info"), nil)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return nil, constants.ErrCreatingHTTPRequest
@@ -109,7 +116,10 @@ func (c *Client) GetAccountInfo() (*AccountInfo, error) {
 	res, err := c.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return nil, constants.ErrDoingHTTPRequest
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return nil, err
 	}
 
 	defer res.Body.Close()
@@ -123,8 +133,13 @@ func (c *Client) GetAccountInfo() (*AccountInfo, error) {
 
 // GetAccountQuota returns AccountQuota about the current account.
 func (c *Client) GetAccountQuota() (*AccountQuota, error) {
+	return c.GetAccountQuotaContext(context.Background())
+}
+
+// GetAccountQuotaContext is like GetAccountQuota but uses ctx for the request.
+func (c *Client) GetAccountQuotaContext(ctx context.Context) (*AccountQuota, error) {
 	var aq AccountQuota
-	req, err := http.NewRequest("GET", c.GetMetadataURL("account/quota"), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.GetMetadataURL("account/quota"), nil)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return nil, constants.ErrCreatingHTTPRequest
@@ -133,7 +148,10 @@ func (c *Client) GetAccountQuota() (*AccountQuota, error) {
 	res, err := c.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return nil, constants.ErrDoingHTTPRequest
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return nil, err
 	}
 
 	defer res.Body.Close()
@@ -147,8 +165,13 @@ func (c *Client) GetAccountQuota() (*AccountQuota, error) {
 
 // GetAccountUsage returns AccountUsage about the current account.
 func (c *Client) GetAccountUsage() (*AccountUsage, error) {
+	return c.GetAccountUsageContext(context.Background())
+}
+
+// GetAccountUsageContext is like GetAccountUsage but uses ctx for the request.
+func (c *Client) GetAccountUsageContext(ctx context.Context) (*AccountUsage, error) {
 	var au AccountUsage
-	req, err := http.NewRequest("GET", c.GetMetadataURL("account/usage"), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.GetMetadataURL("account/usage"), nil)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return nil, constants.ErrCreatingHTTPRequest
@@ -157,7 +180,10 @@ func (c *Client) GetAccountUsage() (*AccountUsage, error) {
 	res, err := c.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return nil, constants.ErrDoingHTTPRequest
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return nil, err
 	}
 
 	defer res.Body.Close()
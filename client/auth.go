@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -27,7 +28,14 @@ type apiAuthTokenResponse struct {
 	TokenType        string `json:"token_type"`
 }
 
+// RefreshToken exchanges the configured refresh token for a new access token
+// which is then sent on all subsequent requests.
 func (c *Client) RefreshToken() error {
+	return c.RefreshTokenContext(context.Background())
+}
+
+// RefreshTokenContext is like RefreshToken but uses ctx for the request.
+func (c *Client) RefreshTokenContext(ctx context.Context) error {
 	log.Debug("client.RefreshToken starting.")
 	defer log.Debug("client.RefreshToken completed.")
 
@@ -40,7 +48,11 @@ func (c *Client) RefreshToken() error {
 		SourceToken:        c.config.RefreshToken,
 		SourceTokenType:    "refresh_token",
 	}
+	tokenURL := c.config.TokenURL
 	c.config.mutex.RUnlock()
+	if tokenURL == "" {
+		tokenURL = constants.AmazonAPITokenURL
+	}
 	requestJsonBytes, err := json.Marshal(request)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrJSONEncoding, err)
@@ -48,7 +60,7 @@ func (c *Client) RefreshToken() error {
 	}
 
 	// Build Request
-	req, err := http.NewRequest("POST", constants.AmazonAPITokenURL, bytes.NewBuffer(requestJsonBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBuffer(requestJsonBytes))
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return constants.ErrCreatingHTTPRequest
@@ -59,7 +71,7 @@ func (c *Client) RefreshToken() error {
 	res, err := c.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return constants.ErrDoingHTTPRequest
+		return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
 	}
 	if err := c.CheckResponse(res); err != nil {
 		return err
@@ -76,7 +88,16 @@ func (c *Client) RefreshToken() error {
 	log.Debugf("Refresh token response: %+v\n", response)
 
 	if response.Error != "" || response.ErrorDescription != "" {
-		return fmt.Errorf("Failed to refresh access token. Error: %s - %s", response.Error, response.ErrorDescription)
+		apiErr := &constants.APIError{
+			StatusCode: res.StatusCode,
+			Method:     req.Method,
+			URL:        req.URL.Redacted(),
+			Code:       response.Error,
+			Message:    response.ErrorDescription,
+			RequestId:  response.RequestId,
+		}
+		log.Errorf("failed to refresh access token: %s", apiErr)
+		return apiErr
 	}
 
 	c.config.mutex.Lock()
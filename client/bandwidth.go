@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+// scheduleTimeLayout is the layout of the times of day of a
+// BandwidthSchedule.
+const scheduleTimeLayout = "15:04"
+
+type (
+	// BandwidthLimit caps the rates of the uploads and of the downloads. The
+	// rates are in bytes per second, shared by all the transfers of the
+	// client, 0 meaning unlimited.
+	BandwidthLimit struct {
+		Upload   int64 `json:"upload"`
+		Download int64 `json:"download"`
+
+		// Schedules override Upload and Download during some hours of the
+		// day, the first schedule including the current time applies.
+		Schedules []BandwidthSchedule `json:"schedules"`
+	}
+
+	// BandwidthSchedule are the rates of a BandwidthLimit from Start until
+	// End, local times of day formatted as 15:04. The schedule spans
+	// midnight if End is before Start.
+	BandwidthSchedule struct {
+		Start    string `json:"start"`
+		End      string `json:"end"`
+		Upload   int64  `json:"upload"`
+		Download int64  `json:"download"`
+	}
+)
+
+func (b *BandwidthLimit) validate() error {
+	rates := []int64{b.Upload, b.Download}
+	for _, s := range b.Schedules {
+		if _, _, err := s.window(); err != nil {
+			return err
+		}
+		rates = append(rates, s.Upload, s.Download)
+	}
+	for _, rate := range rates {
+		if rate < 0 {
+			log.Errorf("%s: negative rate %d", constants.ErrInvalidBandwidthLimit, rate)
+			return constants.ErrInvalidBandwidthLimit
+		}
+	}
+	return nil
+}
+
+// rates returns the upload and download rates at t.
+func (b *BandwidthLimit) rates(t time.Time) (upload, download int64) {
+	minute := t.Hour()*60 + t.Minute()
+	for _, s := range b.Schedules {
+		start, end, err := s.window()
+		if err != nil {
+			continue
+		}
+		if start <= end && minute >= start && minute < end ||
+			start > end && (minute >= start || minute < end) {
+			return s.Upload, s.Download
+		}
+	}
+	return b.Upload, b.Download
+}
+
+// window returns Start and End in minutes since midnight.
+func (s *BandwidthSchedule) window() (start, end int, err error) {
+	startTime, err := time.Parse(scheduleTimeLayout, s.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	endTime, err := time.Parse(scheduleTimeLayout, s.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	return startTime.Hour()*60 + startTime.Minute(), endTime.Hour()*60 + endTime.Minute(), nil
+}
+
+// tokenBucket is a node.Limiter allowing rate bytes per second, with bursts
+// of up to a second of transfer. The rate is read at every call so it follows
+// the schedules.
+type tokenBucket struct {
+	rate func(time.Time) int64
+	now  func() time.Time
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// WaitN reserves n bytes, waiting until the bucket has refilled if it is
+// empty. The reservations are served in order, a transfer reading more than
+// the bucket holds is delayed accordingly.
+func (tb *tokenBucket) WaitN(ctx context.Context, n int) error {
+	tb.mutex.Lock()
+	now := tb.now()
+	rate := float64(tb.rate(now))
+	if rate <= 0 {
+		tb.tokens, tb.last = 0, time.Time{}
+		tb.mutex.Unlock()
+		return ctx.Err()
+	}
+	if tb.last.IsZero() {
+		tb.tokens = rate
+	} else {
+		tb.tokens += now.Sub(tb.last).Seconds() * rate
+	}
+	tb.tokens = min(tb.tokens, rate) - float64(n)
+	tb.last = now
+	wait := time.Duration(-tb.tokens / rate * float64(time.Second))
+	tb.mutex.Unlock()
+
+	return sleepContext(ctx, wait)
+}
+
+// GetUploadLimiter returns the node.Limiter of the uploads, nil if the
+// config has no BandwidthLimit.
+func (c *Client) GetUploadLimiter() node.Limiter {
+	if c.uploadLimiter == nil {
+		return nil
+	}
+	return c.uploadLimiter
+}
+
+// GetDownloadLimiter returns the node.Limiter of the downloads, nil if the
+// config has no BandwidthLimit.
+func (c *Client) GetDownloadLimiter() node.Limiter {
+	if c.downloadLimiter == nil {
+		return nil
+	}
+	return c.downloadLimiter
+}
+
+// setLimiters creates the token buckets of the BandwidthLimit of the config.
+func (c *Client) setLimiters() {
+	limit := c.config.BandwidthLimit
+	if limit == nil {
+		return
+	}
+	c.uploadLimiter = &tokenBucket{
+		rate: func(t time.Time) int64 {
+			upload, _ := limit.rates(t)
+			return upload
+		},
+		now: time.Now,
+	}
+	c.downloadLimiter = &tokenBucket{
+		rate: func(t time.Time) int64 {
+			_, download := limit.rates(t)
+			return download
+		},
+		now: time.Now,
+	}
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+func TestBandwidthLimitRates(t *testing.T) {
+	limit := &BandwidthLimit{
+		Upload:   100,
+		Download: 200,
+		Schedules: []BandwidthSchedule{
+			{Start: "09:00", End: "18:00", Upload: 10, Download: 20},
+			{Start: "22:00", End: "06:30", Upload: 0, Download: 0},
+		},
+	}
+	if err := limit.validate(); err != nil {
+		t.Fatalf("validate() error: %s", err)
+	}
+	tests := map[string]struct{ upload, download int64 }{
+		"08:59": {100, 200},
+		"09:00": {10, 20},
+		"17:59": {10, 20},
+		"18:00": {100, 200},
+		"23:15": {0, 0},
+		"03:00": {0, 0},
+		"06:30": {100, 200},
+	}
+	for clock, want := range tests {
+		at, _ := time.Parse(scheduleTimeLayout, clock)
+		if upload, download := limit.rates(at); upload != want.upload || download != want.download {
+			t.Errorf("rates(%s): want %d/%d got %d/%d", clock, want.upload, want.download, upload, download)
+		}
+	}
+
+	invalid := map[string]*BandwidthLimit{
+		"negative rate": {Download: -1},
+		"invalid time":  {Schedules: []BandwidthSchedule{{Start: "9am", End: "18:00"}}},
+	}
+	for name, limit := range invalid {
+		if err := limit.validate(); err == nil {
+			t.Errorf("%s: validate(): want an error got nil", name)
+		}
+	}
+	if err := invalid["negative rate"].validate(); err != constants.ErrInvalidBandwidthLimit {
+		t.Errorf("validate() negative rate: want %s got %v", constants.ErrInvalidBandwidthLimit, err)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tb := &tokenBucket{
+		rate: func(time.Time) int64 { return 1000 },
+		now:  func() time.Time { return now },
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := tb.WaitN(ctx, 1000); err != nil {
+		t.Fatalf("WaitN() a full bucket: want nil got %s", err)
+	}
+	if err := tb.WaitN(ctx, 500); err != context.DeadlineExceeded {
+		t.Fatalf("WaitN() an empty bucket: want %s got %v", context.DeadlineExceeded, err)
+	}
+	// the cancelled wait keeps its reservation.
+	now = now.Add(time.Second)
+	if err := tb.WaitN(context.Background(), 500); err != nil {
+		t.Fatalf("WaitN() a refilled bucket: want nil got %s", err)
+	}
+}
+
+func TestBandwidthLimitDownload(t *testing.T) {
+	server := newTestServer(t)
+	content := bytes.Repeat([]byte("x"), 30000)
+	if _, err := server.PutFile("/big.bin", content); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, server, "")
+	c.config.BandwidthLimit = &BandwidthLimit{Download: 20000}
+	c.setLimiters()
+
+	start := time.Now()
+	body, err := c.Download("/big.bin")
+	if err != nil {
+		t.Fatalf("c.Download() error: %s", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil || !bytes.Equal(content, got) {
+		t.Fatalf("c.Download() content: want %d bytes got %d (%v)", len(content), len(got), err)
+	}
+	// a second of burst, then 10000 bytes at 20000 bytes per second.
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("c.Download() with a limit of 20000 B/s: want at least 400ms got %s", elapsed)
+	}
+
+	start = time.Now()
+	if _, err := c.Upload("/small.bin", false, nil, node.NewProperty(), bytes.NewReader(content)); err != nil {
+		t.Fatalf("c.Upload() error: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Errorf("c.Upload() without an upload limit: want less than 400ms got %s", elapsed)
+	}
+}
@@ -20,15 +20,19 @@ type Client struct {
 	config           *Config
 	httpClient       *http.Client
 	cacheFile        string
+	downloadLimiter  *tokenBucket
 	endpoints        apiEndpointResponse
 	purgeTrashDone   chan struct{}
 	refreshTokenDone chan struct{}
+	retryWaitMin     time.Duration
+	retryWaitMax     time.Duration
+	uploadLimiter    *tokenBucket
 }
 
 // New returns a new Amazon Cloud Drive "acd" Client
 func New(config *Config) (*Client, error) {
 	// Validate configs
-	if config.CacheFile == "" {
+	if config.CacheFile == "" && !config.LazyTree {
 		return nil, constants.ErrCacheFileConfigEmpty
 	}
 	if config.AppName == "" {
@@ -37,9 +41,24 @@ func New(config *Config) (*Client, error) {
 	if config.AppVersion == "" {
 		config.AppVersion = runtime.Version()
 	}
+	if config.EndpointURL == "" {
+		config.EndpointURL = constants.AmazonDriveEndpointURL
+	}
 	if config.Headers == nil {
 		config.Headers = map[string]string{}
 	}
+	if config.LazyTreeTTL == "" {
+		config.LazyTreeTTL = "5m"
+	}
+	if config.RetryMaxAttempts < 1 {
+		config.RetryMaxAttempts = 3
+	}
+	if config.RetryWaitMin == "" {
+		config.RetryWaitMin = "1s"
+	}
+	if config.RetryWaitMax == "" {
+		config.RetryWaitMax = "30s"
+	}
 	if config.SyncChunkSize < 1 {
 		config.SyncChunkSize = 25
 	}
@@ -49,6 +68,9 @@ func New(config *Config) (*Client, error) {
 	if config.Timeout == "" {
 		config.Timeout = "0"
 	}
+	if config.TokenURL == "" {
+		config.TokenURL = constants.AmazonAPITokenURL
+	}
 	//if config.UserAgent == "" {
 	//	config.UserAgent = "CloudDriveMac/10.4.0.3655d303"
 	//}
@@ -58,13 +80,39 @@ func New(config *Config) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	retryWaitMin, err := time.ParseDuration(config.RetryWaitMin)
+	if err != nil {
+		return nil, err
+	}
+	retryWaitMax, err := time.ParseDuration(config.RetryWaitMax)
+	if err != nil {
+		return nil, err
+	}
+	if config.PurgeTrashPolicy != nil {
+		if err := config.PurgeTrashPolicy.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if config.BandwidthLimit != nil {
+		if err := config.BandwidthLimit.validate(); err != nil {
+			return nil, err
+		}
+	}
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout:   timeout,
+			Transport: config.Transport,
+		}
+	}
 	c := &Client{
-		config:    config,
-		cacheFile: config.CacheFile,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		config:       config,
+		cacheFile:    config.CacheFile,
+		httpClient:   httpClient,
+		retryWaitMin: retryWaitMin,
+		retryWaitMax: retryWaitMax,
 	}
+	c.setLimiters()
 
 	// If a refresh token is set, try to get a new access token and setup background refresh
 	if c.config.RefreshToken != "" {
@@ -127,9 +175,21 @@ func New(config *Config) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	nt, err := node.NewTree(c, c.cacheFile, config.SyncChunkSize, syncInterval)
-	if err != nil {
-		return nil, err
+	var nt *node.Tree
+	if config.LazyTree {
+		lazyTreeTTL, err := time.ParseDuration(config.LazyTreeTTL)
+		if err != nil {
+			return nil, err
+		}
+		nt, err = node.NewLazyTree(c, config.SyncChunkSize, syncInterval, lazyTreeTTL)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		nt, err = node.NewTree(c, c.cacheFile, config.SyncChunkSize, syncInterval)
+		if err != nil {
+			return nil, err
+		}
 	}
 	c.nodeTree = nt
 
@@ -148,16 +208,50 @@ func (c *Client) Close() error {
 }
 
 // Do invokes net/http.Client.Do(). Refer to net/http.Client.Do() for documentation.
+// Requests failing with a transient error are retried according to the retry
+// settings of the Config, see retry.go.
 func (c *Client) Do(r *http.Request) (*http.Response, error) {
 	c.config.mutex.RLock()
+	maxAttempts := c.config.RetryMaxAttempts
+	c.config.mutex.RUnlock()
+	if maxAttempts < 1 || !isReplayable(r) {
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			if err := rewindBody(r); err != nil {
+				return nil, err
+			}
+		}
+		c.setHeaders(r)
+		res, err := c.httpClient.Do(r)
+		if attempt >= maxAttempts || !shouldRetry(r, res, err) {
+			return res, err
+		}
+
+		wait := c.backoff(attempt, res)
+		if err != nil {
+			log.Infof("retrying %s %s in %s (attempt %d of %d): %s", r.Method, r.URL.Redacted(), wait, attempt+1, maxAttempts, err)
+		} else {
+			log.Infof("retrying %s %s in %s (attempt %d of %d): %s", r.Method, r.URL.Redacted(), wait, attempt+1, maxAttempts, res.Status)
+			drainBody(res)
+		}
+		if err := sleepContext(r.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) setHeaders(r *http.Request) {
+	c.config.mutex.RLock()
+	defer c.config.mutex.RUnlock()
 	for key, value := range c.config.Headers {
 		r.Header.Set(key, value)
 	}
 	if c.config.UserAgent != "" {
 		r.Header.Set("User-Agent", c.config.UserAgent)
 	}
-	c.config.mutex.RUnlock()
-	return c.httpClient.Do(r)
 }
 
 func LoadConfig(configFile string) (*Config, error) {
@@ -0,0 +1,660 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/montaguethomas/acd-go/acdtest"
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+// newTestClient returns a client for the fake server using cacheFile, or a new
+// cache file if empty. The client is closed at the end of the test.
+func newTestClient(t *testing.T, server *acdtest.Server, cacheFile string) *Client {
+	t.Helper()
+	if cacheFile == "" {
+		cacheFile = filepath.Join(t.TempDir(), "acd-cache")
+	}
+	c, err := New(&Config{
+		CacheFile:    cacheFile,
+		EndpointURL:  server.EndpointURL(),
+		RefreshToken: server.RefreshToken,
+		RetryWaitMin: "1ms",
+		RetryWaitMax: "5ms",
+		SyncInterval: "1h",
+		TokenURL:     server.TokenURL(),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %s", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func newTestServer(t *testing.T) *acdtest.Server {
+	t.Helper()
+	server := acdtest.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewSyncsExistingNodes(t *testing.T) {
+	server := newTestServer(t)
+	if _, err := server.PutFile("/docs/README.md", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, server, "")
+	n, err := c.GetNodeTree().FindNode("/docs/readme.md")
+	if err != nil {
+		t.Fatalf("c.GetNodeTree().FindNode() error: %s", err)
+	}
+	if want, got := uint64(5), n.ContentProperties.Size; want != got {
+		t.Errorf("FindNode().ContentProperties.Size: want %d got %d", want, got)
+	}
+
+	ai, err := c.GetAccountInfo()
+	if err != nil {
+		t.Fatalf("c.GetAccountInfo() error: %s", err)
+	}
+	if want, got := "ACTIVE", ai.Status; want != got {
+		t.Errorf("c.GetAccountInfo().Status: want %s got %s", want, got)
+	}
+}
+
+func TestNewWithInvalidRefreshToken(t *testing.T) {
+	server := newTestServer(t)
+	_, err := New(&Config{
+		CacheFile:    filepath.Join(t.TempDir(), "acd-cache"),
+		EndpointURL:  server.EndpointURL(),
+		RefreshToken: "invalid",
+		TokenURL:     server.TokenURL(),
+	})
+	if !errors.Is(err, constants.ErrResponseBadInput) {
+		t.Fatalf("New() with an invalid token: want %s got %v", constants.ErrResponseBadInput, err)
+	}
+	var apiErr *constants.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "invalid_grant" {
+		t.Errorf("New() with an invalid token: want invalid_grant APIError got %#v", err)
+	}
+}
+
+func TestNewWithCustomTransport(t *testing.T) {
+	server := newTestServer(t)
+	if _, err := server.PutFile("/file", []byte("content")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default URLs are sent to the fake by its transport.
+	configs := map[string]*Config{
+		"Transport":  {Transport: server.Transport()},
+		"HTTPClient": {HTTPClient: server.Client()},
+	}
+	for name, config := range configs {
+		config.CacheFile = filepath.Join(t.TempDir(), "acd-cache")
+		config.RefreshToken = server.RefreshToken
+		config.SyncInterval = "1h"
+		c, err := New(config)
+		if err != nil {
+			t.Fatalf("New() with %s error: %s", name, err)
+		}
+		if _, err := c.GetNodeTree().FindNode("/file"); err != nil {
+			t.Errorf("New() with %s, FindNode() error: %s", name, err)
+		}
+		c.Close()
+	}
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+
+	content := []byte("the quick brown fox")
+	n, err := c.Upload("/a/b/fox.txt", false, nil, node.NewProperty(), bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("c.Upload() error: %s", err)
+	}
+	if got, err := server.ReadFile("/a/b/fox.txt"); err != nil || !bytes.Equal(content, got) {
+		t.Errorf("server content: want %q got %q (%v)", content, got, err)
+	}
+
+	if _, err := c.Upload("/a/b/fox.txt", false, nil, node.NewProperty(), bytes.NewReader(content)); err != constants.ErrFileExists {
+		t.Errorf("c.Upload() existing file: want %s got %v", constants.ErrFileExists, err)
+	}
+
+	updated := []byte("jumps over the lazy dog")
+	if _, err := c.Upload("/a/b/fox.txt", true, nil, node.NewProperty(), bytes.NewReader(updated)); err != nil {
+		t.Fatalf("c.Upload() overwrite error: %s", err)
+	}
+	if want, got := uint64(len(updated)), n.ContentProperties.Size; want != got {
+		t.Errorf("overwritten node size: want %d got %d", want, got)
+	}
+
+	body, err := c.Download("/A/B/FOX.txt")
+	if err != nil {
+		t.Fatalf("c.Download() error: %s", err)
+	}
+	defer body.Close()
+	got, _ := io.ReadAll(body)
+	if !bytes.Equal(updated, got) {
+		t.Errorf("c.Download(): want %q got %q", updated, got)
+	}
+
+	if _, err := c.Upload("/a/empty", false, nil, node.NewProperty(), bytes.NewReader(nil)); err != constants.ErrNoContentsToUpload {
+		t.Errorf("c.Upload() empty file: want %s got %v", constants.ErrNoContentsToUpload, err)
+	}
+}
+
+func TestUploadFolderAndDownloadFolder(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+
+	local := t.TempDir()
+	files := map[string]string{
+		"README":            "readme",
+		"sub/one.txt":       "one",
+		"sub/deeper/two.md": "two",
+	}
+	for name, content := range files {
+		p := filepath.Join(local, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.UploadFolder(local, "/backup", true, false, nil, node.NewProperty()); err != nil {
+		t.Fatalf("c.UploadFolder() error: %s", err)
+	}
+	for name, content := range files {
+		got, err := server.ReadFile("/backup/" + name)
+		if err != nil || string(got) != content {
+			t.Errorf("server content of %s: want %q got %q (%v)", name, content, got, err)
+		}
+	}
+
+	// uploading again is a no-op as the md5 are the same.
+	requests := server.Requests()
+	if err := c.UploadFolder(local, "/backup", true, false, nil, node.NewProperty()); err != nil {
+		t.Fatalf("c.UploadFolder() again error: %s", err)
+	}
+	if want, got := requests, server.Requests(); want != got {
+		t.Errorf("c.UploadFolder() again: want no requests got %d", got-want)
+	}
+
+	dst := filepath.Join(t.TempDir(), "restore")
+	if err := c.DownloadFolder(dst, "/backup", true); err != nil {
+		t.Fatalf("c.DownloadFolder() error: %s", err)
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil || string(got) != content {
+			t.Errorf("downloaded content of %s: want %q got %q (%v)", name, content, got, err)
+		}
+	}
+}
+
+func TestUploadFolderMirror(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"/backup/old.txt", "/backup/keep.txt", "/backup/gone/a.txt", "/backup/gone/b.txt"} {
+		if _, err := server.PutFile(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestClient(t, server, "")
+
+	local := t.TempDir()
+	os.MkdirAll(filepath.Join(local, "empty", "nested"), 0755)
+	if err := os.WriteFile(filepath.Join(local, "keep.txt"), []byte("new content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(local, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &UploadFolderOptions{Recursive: true, Overwrite: true, Mirror: true, MaxDeletes: 1}
+	summary, err := c.UploadFolderWithOptions(local, "/backup", opts)
+	if err != constants.ErrTooManyDeletions {
+		t.Fatalf("c.UploadFolderWithOptions() above the threshold: want %s got %v", constants.ErrTooManyDeletions, err)
+	}
+	if _, ok := server.Lookup("/backup/gone/a.txt"); !ok {
+		t.Errorf("c.UploadFolderWithOptions() above the threshold: /backup/gone was trashed")
+	}
+	// the threshold is checked before any change.
+	if _, ok := server.Lookup("/backup/new.txt"); ok {
+		t.Errorf("c.UploadFolderWithOptions() above the threshold: /backup/new.txt was uploaded")
+	}
+	if !reflect.DeepEqual(&UploadFolderSummary{}, summary) {
+		t.Errorf("c.UploadFolderWithOptions() above the threshold summary: want no changes got %+v", summary)
+	}
+
+	opts.MaxDeletes = 2
+	summary, err = c.UploadFolderWithOptions(local, "/backup", opts)
+	if err != nil {
+		t.Fatalf("c.UploadFolderWithOptions() error: %s", err)
+	}
+	for _, name := range []string{"/backup/old.txt", "/backup/gone"} {
+		if _, ok := server.Lookup(name); ok {
+			t.Errorf("%s exists after the mirror upload", name)
+		}
+	}
+	if n, ok := server.Lookup("/backup/empty/nested"); !ok || !n.IsDir() {
+		t.Errorf("/backup/empty/nested: want a folder got %v", n)
+	}
+	if got, err := server.ReadFile("/backup/keep.txt"); err != nil || string(got) != "new content" {
+		t.Errorf("/backup/keep.txt: want %q got %q (%v)", "new content", got, err)
+	}
+
+	want := &UploadFolderSummary{
+		CreatedFolders: []string{"/backup/empty", "/backup/empty/nested"},
+		Uploaded:       []string{"/backup/new.txt"},
+		Updated:        []string{"/backup/keep.txt"},
+		Deleted:        []string{"/backup/gone", "/backup/old.txt"},
+	}
+	if !reflect.DeepEqual(want, summary) {
+		t.Errorf("c.UploadFolderWithOptions() summary: want %+v got %+v", want, summary)
+	}
+
+	// mirroring again changes nothing.
+	if summary, err = c.UploadFolderWithOptions(local, "/backup", opts); err != nil {
+		t.Fatalf("c.UploadFolderWithOptions() again error: %s", err)
+	}
+	if !reflect.DeepEqual(&UploadFolderSummary{}, summary) {
+		t.Errorf("c.UploadFolderWithOptions() again summary: want no changes got %+v", summary)
+	}
+}
+
+func TestUploadFolderWorkers(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+
+	local := t.TempDir()
+	files := map[string]string{}
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("album%d/day%d/IMG_%04d.jpg", i%2, i%3, i)
+		files[name] = fmt.Sprintf("photo %d", i)
+		p := filepath.Join(local, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// concurrent calls to MkDirAll create the folders once.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetNodeTree().MkDirAll("/photos/album0/day0"); err != nil {
+				t.Errorf("c.GetNodeTree().MkDirAll() error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, folder := range []string{"/photos", "/photos/album0"} {
+		children, ok := server.Children(folder)
+		if !ok || len(children) != 1 {
+			t.Fatalf("server children of %s: want 1 folder got %v", folder, children)
+		}
+	}
+
+	summary, err := c.UploadFolderWithOptions(local, "/photos", &UploadFolderOptions{Recursive: true, Workers: 8})
+	if err != nil {
+		t.Fatalf("c.UploadFolderWithOptions() error: %s", err)
+	}
+	if want, got := len(files), len(summary.Uploaded); want != got {
+		t.Errorf("c.UploadFolderWithOptions() uploaded: want %d files got %d", want, got)
+	}
+	if !sort.StringsAreSorted(summary.Uploaded) {
+		t.Errorf("c.UploadFolderWithOptions() uploaded: want sorted paths got %q", summary.Uploaded)
+	}
+	for name, content := range files {
+		got, err := server.ReadFile("/photos/" + name)
+		if err != nil || string(got) != content {
+			t.Errorf("server content of %s: want %q got %q (%v)", name, content, got, err)
+		}
+	}
+	// the workers create every folder once.
+	for folder, want := range map[string]int{"/photos": 2, "/photos/album0": 3, "/photos/album1": 3} {
+		if children, _ := server.Children(folder); len(children) != want {
+			t.Errorf("server children of %s: want %d folders got %d", folder, want, len(children))
+		}
+	}
+}
+
+func TestFolderProgress(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+
+	local := t.TempDir()
+	files := map[string]string{
+		"a.txt":       "aaaa",
+		"sub/b.txt":   "bb",
+		"sub/c/d.txt": "d",
+	}
+	for name, content := range files {
+		p := filepath.Join(local, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var reports []FolderProgress
+	ctx := WithFolderProgress(context.Background(), func(p FolderProgress) {
+		reports = append(reports, p)
+	})
+	check := func(name string) {
+		t.Helper()
+		if len(reports) == 0 {
+			t.Fatalf("%s: want progress reports got none", name)
+		}
+		last := reports[len(reports)-1]
+		if last.FilesDone != len(files) || last.FilesTotal != len(files) {
+			t.Errorf("%s: want %d of %d files done got %d of %d", name, len(files), len(files), last.FilesDone, last.FilesTotal)
+		}
+		var bytes int64
+		for _, p := range reports {
+			if p.File.Bytes == p.File.Total {
+				bytes += p.File.Bytes
+			}
+		}
+		if bytes == 0 {
+			t.Errorf("%s: want the bytes of the files got none", name)
+		}
+	}
+
+	if _, err := c.UploadFolderWithOptionsContext(ctx, local, "/backup", &UploadFolderOptions{Recursive: true, Workers: 2}); err != nil {
+		t.Fatalf("c.UploadFolderWithOptionsContext() error: %s", err)
+	}
+	check("upload")
+
+	reports = nil
+	if err := c.DownloadFolderContext(ctx, filepath.Join(t.TempDir(), "restore"), "/backup", true); err != nil {
+		t.Fatalf("c.DownloadFolderContext() error: %s", err)
+	}
+	check("download")
+}
+
+func TestTreeSyncBetweenClients(t *testing.T) {
+	server := newTestServer(t)
+	c1 := newTestClient(t, server, "")
+	c2 := newTestClient(t, server, "")
+
+	if _, err := c1.Upload("/shared/file.txt", false, nil, node.NewProperty(), bytes.NewBufferString("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c2.GetNodeTree().Sync(); err != nil {
+		t.Fatalf("c2.GetNodeTree().Sync() error: %s", err)
+	}
+	n, err := c2.GetNodeTree().FindNode("/shared/file.txt")
+	if err != nil {
+		t.Fatalf("c2.GetNodeTree().FindNode() error: %s", err)
+	}
+
+	if err := c1.GetNodeTree().RemoveNode(n); err != nil {
+		t.Fatalf("c1.GetNodeTree().RemoveNode() error: %s", err)
+	}
+	if err := c2.GetNodeTree().Sync(); err != nil {
+		t.Fatalf("c2.GetNodeTree().Sync() error: %s", err)
+	}
+	if _, err := c2.GetNodeTree().FindNode("/shared/file.txt"); err != constants.ErrNodeNotFound {
+		t.Errorf("c2.GetNodeTree().FindNode() trashed node: want %s got %v", constants.ErrNodeNotFound, err)
+	}
+}
+
+func TestCacheIsReloaded(t *testing.T) {
+	server := newTestServer(t)
+	cacheFile := filepath.Join(t.TempDir(), "acd-cache")
+	c := newTestClient(t, server, cacheFile)
+	if _, err := c.Upload("/cached.txt", false, nil, node.NewProperty(), bytes.NewBufferString("cached")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("c.Close() error: %s", err)
+	}
+
+	// the changes since the checkpoint of the cache are empty.
+	c = newTestClient(t, server, cacheFile)
+	if _, err := c.GetNodeTree().FindNode("/cached.txt"); err != nil {
+		t.Errorf("reloaded cache, FindNode() error: %s", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"/trash/a", "/trash/b", "/keep"} {
+		if _, err := server.PutFile(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/trash/a", "/trash/b"} {
+		if err := server.Trash(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestClient(t, server, "")
+
+	nodes, err := c.GetTrash()
+	if err != nil {
+		t.Fatalf("c.GetTrash() error: %s", err)
+	}
+	if want, got := 2, len(nodes); want != got {
+		t.Fatalf("len(c.GetTrash()): want %d got %d", want, got)
+	}
+
+	if err := c.PurgeTrash(); err != nil {
+		t.Fatalf("c.PurgeTrash() error: %s", err)
+	}
+	if nodes, err = c.GetTrash(); err != nil || len(nodes) != 0 {
+		t.Errorf("c.GetTrash() after purge: want no nodes got %d (%v)", len(nodes), err)
+	}
+	if _, ok := server.Lookup("/keep"); !ok {
+		t.Errorf("/keep was purged")
+	}
+}
+
+func TestPurgeTrashWithPolicy(t *testing.T) {
+	server := newTestServer(t)
+	now := time.Now()
+	ids := map[string]string{}
+	for _, name := range []string{"/old/a", "/old/keep/b", "/recent", "/photos/a.jpg", "/photos/keep/b.jpg"} {
+		id, err := server.PutFile(name, []byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+	server.SetClock(func() time.Time { return now.Add(-90 * 24 * time.Hour) })
+	// /photos is purged with its descendants, one of them is excluded.
+	for _, name := range []string{"/old/a", "/old/keep/b", "/photos"} {
+		if err := server.Trash(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.SetClock(time.Now)
+	if err := server.Trash("/recent"); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(&Config{
+		CacheFile:    filepath.Join(t.TempDir(), "acd-cache"),
+		EndpointURL:  server.EndpointURL(),
+		RefreshToken: server.RefreshToken,
+		PurgeTrashPolicy: &PurgeTrashPolicy{
+			MinAge:       "720h",
+			ExcludePaths: []string{"/*/keep"},
+		},
+		SyncInterval: "1h",
+		TokenURL:     server.TokenURL(),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %s", err)
+	}
+	defer c.Close()
+
+	nodes, err := c.PurgeTrashDryRun()
+	if err != nil {
+		t.Fatalf("c.PurgeTrashDryRun() error: %s", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "a" {
+		t.Fatalf("c.PurgeTrashDryRun(): want [a] got %v", nodes)
+	}
+	if trash, _ := c.GetTrash(); len(trash) != 4 {
+		t.Errorf("c.PurgeTrashDryRun() purged nodes: want 4 nodes in the trash got %d", len(trash))
+	}
+
+	if err := c.PurgeTrash(); err != nil {
+		t.Fatalf("c.PurgeTrash() error: %s", err)
+	}
+	trash, err := c.GetTrash()
+	if err != nil || len(trash) != 3 {
+		t.Errorf("c.GetTrash() after purge: want 3 nodes got %d (%v)", len(trash), err)
+	}
+	for _, n := range trash {
+		if n.Name == "a" {
+			t.Errorf("c.PurgeTrash() did not purge a")
+		}
+	}
+	if n, ok := server.Node(ids["/photos/keep/b.jpg"]); !ok || n.Status != node.StatusAvailable {
+		t.Errorf("c.PurgeTrash() purged the excluded /photos/keep/b.jpg")
+	}
+}
+
+func TestPurgeTrashWithPolicyLazyTree(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"/important/keep.txt", "/tmp/old.txt"} {
+		if _, err := server.PutFile(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/important/keep.txt", "/tmp/old.txt"} {
+		if err := server.Trash(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the parents of the trashed nodes are not loaded in the tree.
+	c, err := New(&Config{
+		EndpointURL:  server.EndpointURL(),
+		LazyTree:     true,
+		RefreshToken: server.RefreshToken,
+		PurgeTrashPolicy: &PurgeTrashPolicy{
+			ExcludePaths: []string{"/important"},
+		},
+		SyncInterval: "1h",
+		TokenURL:     server.TokenURL(),
+	})
+	if err != nil {
+		t.Fatalf("New() error: %s", err)
+	}
+	defer c.Close()
+
+	nodes, err := c.PurgeTrashDryRun()
+	if err != nil {
+		t.Fatalf("c.PurgeTrashDryRun() error: %s", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "old.txt" {
+		t.Fatalf("c.PurgeTrashDryRun(): want [old.txt] got %v", nodes)
+	}
+	view, err := c.GetTrashView(&TrashFilter{PathPrefix: "/important"})
+	if err != nil {
+		t.Fatalf("c.GetTrashView() error: %s", err)
+	}
+	if len(view.Items) != 1 || view.Items[0].OriginalPath != "/important/keep.txt" {
+		t.Errorf("c.GetTrashView() original paths: want [/important/keep.txt] got %v", view.Items)
+	}
+}
+
+func TestRestoreNodes(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+	for _, name := range []string{"/docs/a.txt", "/docs/b.txt", "/folder/sub/c.txt"} {
+		if _, err := c.Upload(name, false, nil, node.NewProperty(), bytes.NewBufferString(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/docs/a.txt", "/docs/b.txt", "/folder"} {
+		n, _ := c.GetNodeTree().FindNode(name)
+		if err := c.GetNodeTree().RemoveNode(n); err != nil {
+			t.Fatalf("RemoveNode(%q) error: %s", name, err)
+		}
+	}
+	if _, err := c.Upload("/docs/b.txt", false, nil, node.NewProperty(), bytes.NewBufferString("new b")); err != nil {
+		t.Fatal(err)
+	}
+	trash, err := c.GetTrash()
+	if err != nil {
+		t.Fatalf("c.GetTrash() error: %s", err)
+	}
+	trashed := map[string]*node.Node{}
+	for _, n := range trash {
+		trashed[n.Name] = n
+	}
+
+	if _, err := c.RestoreNode(trashed["a.txt"]); err != nil {
+		t.Fatalf("c.RestoreNode() error: %s", err)
+	}
+	if _, err := c.GetNodeTree().FindNode("/docs/a.txt"); err != nil {
+		t.Errorf("restored node, FindNode() error: %s", err)
+	}
+
+	available, _ := c.GetNodeTree().FindNode("/docs/a.txt")
+	restored, err := c.RestoreNodes([]*node.Node{trashed["folder"], trashed["b.txt"], available})
+	var failures NodeErrors
+	if !errors.As(err, &failures) || len(failures) != 2 {
+		t.Fatalf("c.RestoreNodes(): want 2 NodeErrors got %v", err)
+	}
+	if want, got := constants.ErrResponseDuplicateExists, failures[trashed["b.txt"].Id]; want != got {
+		t.Errorf("c.RestoreNodes() conflicting node: want %s got %v", want, got)
+	}
+	if want, got := constants.ErrResponseBadInput, failures[available.Id]; want != got {
+		t.Errorf("c.RestoreNodes() available node: want %s got %v", want, got)
+	}
+	if len(restored) != 1 || restored[0].Name != "folder" {
+		t.Fatalf("c.RestoreNodes(): want the restored folder got %v", restored)
+	}
+	if restored[0] == trashed["folder"] || restored[0].Status != node.StatusAvailable {
+		t.Errorf("c.RestoreNodes(): want the folder as the server returns it got %+v", restored[0])
+	}
+	if want, got := node.StatusTrash, trashed["folder"].Status; want != got {
+		t.Errorf("c.RestoreNodes() changed the node of the trash: want %s got %s", want, got)
+	}
+	// the children of a folder are restored with it.
+	n, err := c.GetNodeTree().FindNode("/folder/sub/c.txt")
+	if err != nil {
+		t.Fatalf("restored folder, FindNode() error: %s", err)
+	}
+	if got, err := c.GetNodeTree().FindById(n.Id); err != nil || got != n {
+		t.Errorf("restored folder, FindById() want the node got %v (%v)", got, err)
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+
+	server.FailNext(http.StatusServiceUnavailable, 2)
+	if _, err := c.GetAccountQuota(); err != nil {
+		t.Fatalf("c.GetAccountQuota() error: %s", err)
+	}
+
+	server.FailNext(http.StatusInternalServerError, 3)
+	_, err := c.GetAccountUsage()
+	if !errors.Is(err, constants.ErrResponseInternalServerError) {
+		t.Errorf("c.GetAccountUsage(): want %s got %v", constants.ErrResponseInternalServerError, err)
+	}
+}
@@ -1,6 +1,9 @@
 package client
 
-import "sync"
+import (
+	"net/http"
+	"sync"
+)
 
 // Config represents the clients configuration.
 type Config struct {
@@ -10,21 +13,64 @@ type Config struct {
 	// AppVersion is the version of the application to send to Amazon
 	AppVersion string `json:"appVersion"`
 
+	// BandwidthLimit caps the rates of the uploads and of the downloads of the
+	// client, optionally depending on the time of day. Unlimited if nil.
+	BandwidthLimit *BandwidthLimit `json:"bandwidthLimit"`
+
 	// CacheFile represents the file used by the client to cache the NodeTree.
 	// This file is not assumed to be present and will be created on the first
-	// run. It is gob-encoded node.Node.
+	// run. It holds the gob-encoded node.Tree behind a header with its format
+	// version and checksum, and is replaced atomically. It is required unless
+	// LazyTree is set.
 	CacheFile string `json:"cacheFile"`
 
+	// EndpointURL overrides the URL used to discover the metadata and content
+	// endpoints. Defaults to constants.AmazonDriveEndpointURL.
+	EndpointURL string `json:"endpointURL"`
+
 	// Headers contains all the additional headers to pass on all requests made.
 	Headers map[string]string `json:"headers"`
 
+	// HTTPClient is the HTTP client used for all requests. When set, Timeout
+	// and Transport are ignored and the client is used as is.
+	HTTPClient *http.Client `json:"-"`
+
+	// LazyTree builds the NodeTree on demand instead of syncing the whole
+	// account before New returns: the folders are listed when they are used
+	// and SyncInterval polls the changes to list them again. See
+	// node.NewLazyTree. The CacheFile is not used.
+	LazyTree bool `json:"lazyTree"`
+
+	// LazyTreeTTL is how long the listing of a folder of a LazyTree is used
+	// before it is listed again. Defaults to 5m, 0 never expires them.
+	LazyTreeTTL string `json:"lazyTreeTTL"`
+
 	// PurgeTrashInterval is how often to purge trash
 	PurgeTrashInterval string `json:"purgeTrashInterval"`
 
+	// PurgeTrashPolicy selects the nodes purged by PurgeTrash, including the
+	// background purge. All the nodes of the trash are purged if it is nil.
+	PurgeTrashPolicy *PurgeTrashPolicy `json:"purgeTrashPolicy"`
+
 	// RefreshToken is an Amazon API Refresh Token
 	// https://developer.amazon.com/docs/login-with-amazon/refresh-token.html
 	RefreshToken string `json:"refreshToken"`
 
+	// RetryMaxAttempts is the maximum number of times a request is sent before
+	// giving up on transient errors (429, 5xx and network errors). Only
+	// requests whose body can be replayed are retried, and the POST requests
+	// only on 429 or on 503 with a Retry-After header. Defaults to 3, set it
+	// to 1 to disable retrying.
+	RetryMaxAttempts int `json:"retryMaxAttempts"`
+
+	// RetryWaitMin is the initial wait between two attempts; it doubles with
+	// every attempt up to RetryWaitMax. A Retry-After header sent by the
+	// server takes precedence, up to RetryWaitMax. Defaults to 1s.
+	RetryWaitMin string `json:"retryWaitMin"`
+
+	// RetryWaitMax caps the wait between two attempts. Defaults to 30s.
+	RetryWaitMax string `json:"retryWaitMax"`
+
 	// SyncChunkSize is the number of nodes to be returned within each Changes
 	// object in the response stream.
 	SyncChunkSize int `json:"syncChunkSize"`
@@ -37,6 +83,14 @@ type Config struct {
 	// See http://godoc.org/net/http#Client for more information.
 	Timeout string `json:"timeout"`
 
+	// TokenURL overrides the URL used to exchange the refresh token for an
+	// access token. Defaults to constants.AmazonAPITokenURL.
+	TokenURL string `json:"tokenURL"`
+
+	// Transport is the http.RoundTripper used for all requests when HTTPClient
+	// is not set. Defaults to http.DefaultTransport.
+	Transport http.RoundTripper `json:"-"`
+
 	// UserAgent is the value to use for the user agent header on all http requests
 	UserAgent string `json:"userAgent"`
 
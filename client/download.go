@@ -1,6 +1,8 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -8,11 +10,18 @@ import (
 
 	"github.com/montaguethomas/acd-go/constants"
 	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
 )
 
 // Download returns an io.ReadCloser for path. The caller is responsible for
 // closing the body.
 func (c *Client) Download(path string) (io.ReadCloser, error) {
+	return c.DownloadContext(context.Background(), path)
+}
+
+// DownloadContext is like Download but uses ctx for the request. Cancelling
+// ctx also aborts reading the returned body.
+func (c *Client) DownloadContext(ctx context.Context, path string) (io.ReadCloser, error) {
 	log.Debugf("downloading %q", path)
 
 	node, err := c.GetNodeTree().FindNode(path)
@@ -20,12 +29,31 @@ func (c *Client) Download(path string) (io.ReadCloser, error) {
 		return nil, err
 	}
 
-	return c.GetNodeTree().Download(node)
+	return c.GetNodeTree().DownloadContext(ctx, node)
 }
 
 // DownloadFolder downloads an entire folder to a path, if recursive is true,
-// it will also download all subfolders.
+// it will also download all subfolders. Files are downloaded to a partial file
+// renamed once complete, so running it again after an interruption resumes
+// the partial files and skips the files already downloaded.
 func (c *Client) DownloadFolder(localPath, remotePath string, recursive bool) error {
+	return c.DownloadFolderContext(context.Background(), localPath, remotePath, recursive)
+}
+
+// DownloadFolderContext is like DownloadFolder but uses ctx for all the
+// requests. It stops at the first file once ctx is done.
+func (c *Client) DownloadFolderContext(ctx context.Context, localPath, remotePath string, recursive bool) error {
+	progress := newFolderProgress(ctx, func() int {
+		rootNode, err := c.GetNodeTree().LookupNode(remotePath)
+		if err != nil {
+			return 0
+		}
+		return c.countRemoteFiles(ctx, rootNode, recursive)
+	})
+	return c.downloadFolder(ctx, localPath, remotePath, recursive, progress)
+}
+
+func (c *Client) downloadFolder(ctx context.Context, localPath, remotePath string, recursive bool, progress *folderProgress) error {
 	log.Debugf("downloading %q to %q", localPath, remotePath)
 
 	if err := os.Mkdir(localPath, os.FileMode(0755)); err != nil && !os.IsExist(err) {
@@ -36,12 +64,15 @@ func (c *Client) DownloadFolder(localPath, remotePath string, recursive bool) er
 	if err != nil {
 		return nil
 	}
+	if err := c.GetNodeTree().LoadChildrenContext(ctx, rootNode); err != nil {
+		return err
+	}
 	for _, node := range rootNode.Nodes {
 		flp := path.Join(localPath, node.Name)
 		frp := fmt.Sprintf("%s/%s", remotePath, node.Name)
 		if node.IsDir() {
 			if recursive {
-				if err := c.DownloadFolder(flp, frp, recursive); err != nil {
+				if err := c.downloadFolder(ctx, flp, frp, recursive, progress); err != nil {
 					return err
 				}
 			}
@@ -49,24 +80,141 @@ func (c *Client) DownloadFolder(localPath, remotePath string, recursive bool) er
 			continue
 		}
 
-		con, err := c.GetNodeTree().Download(node)
-		if err != nil {
+		log.Debugf("saving %s as %s", frp, flp)
+		if err := c.resumeDownload(progress.fileContext(ctx), node, flp); err != nil {
 			return err
 		}
-		f, err := os.Create(flp)
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrCreateFile, flp)
-			return constants.ErrCreateFile
+		progress.fileDone(node.Name, int64(node.Size()))
+	}
+
+	return nil
+}
+
+// partialSuffix is appended to the path of a file being downloaded. The state
+// of the download is saved next to it with the partialStateSuffix.
+const (
+	partialSuffix      = ".partial"
+	partialStateSuffix = ".partial.json"
+)
+
+// partialState identifies the content a partial file is downloading, it is
+// only resumed if the node has not changed since.
+type partialState struct {
+	Id   string `json:"id"`
+	MD5  string `json:"md5"`
+	Size uint64 `json:"size"`
+}
+
+// resumeDownload downloads the content of the node to localPath through a
+// partial file. If a partial file of the same content exists, the download
+// resumes from its last byte with a Range request. The content is verified
+// against the md5 of the node before the partial file is renamed to localPath.
+// Nothing is downloaded if localPath already has the content of the node.
+func (c *Client) resumeDownload(ctx context.Context, n *node.Node, localPath string) error {
+	if isDownloaded(n, localPath) {
+		log.Debugf("%s is already downloaded", localPath)
+		return nil
+	}
+
+	partialPath := localPath + partialSuffix
+	statePath := localPath + partialStateSuffix
+	state := partialState{Id: n.Id, MD5: n.ContentProperties.MD5, Size: n.Size()}
+	var offset int64
+	if readPartialState(statePath) == state {
+		if fi, err := os.Stat(partialPath); err == nil && uint64(fi.Size()) <= state.Size {
+			offset = fi.Size()
 		}
-		log.Debugf("saving %s as %s", frp, flp)
-		_, err = io.Copy(f, con)
-		f.Close()
-		con.Close()
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrWritingFileContents, err)
+	}
+	if offset == 0 {
+		if err := writePartialState(statePath, state); err != nil {
 			return err
 		}
+	} else {
+		log.Debugf("resuming the download of %s at byte %d", localPath, offset)
 	}
 
+	f, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE, os.FileMode(0644))
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	defer f.Close()
+	if err := f.Truncate(offset); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+
+	con, err := c.GetNodeTree().DownloadRangeContext(ctx, n, offset, -1)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.NewOffsetWriter(f, offset), con)
+	con.Close()
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrWritingFileContents, err)
+		return fmt.Errorf("%w: %w", constants.ErrWritingFileContents, err)
+	}
+
+	if err := verifyMD5(f, n); err != nil {
+		// the partial content is wrong, start over next time.
+		f.Close()
+		os.Remove(partialPath)
+		os.Remove(statePath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		log.Errorf("%s: %s", constants.ErrWritingFileContents, err)
+		return constants.ErrWritingFileContents
+	}
+	if err := os.Rename(partialPath, localPath); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	os.Remove(statePath)
+
+	return nil
+}
+
+// isDownloaded returns whether localPath is a file with the content of the
+// node.
+func isDownloaded(n *node.Node, localPath string) bool {
+	fi, err := os.Stat(localPath)
+	if err != nil || !fi.Mode().IsRegular() || uint64(fi.Size()) != n.Size() || n.ContentProperties.MD5 == "" {
+		return false
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	sum, err := md5Sum(f, n)
+	return err == nil && sum == n.ContentProperties.MD5
+}
+
+// readPartialState returns the state saved at statePath, or the zero value if
+// it cannot be read.
+func readPartialState(statePath string) partialState {
+	var state partialState
+	content, err := os.ReadFile(statePath)
+	if err != nil {
+		return partialState{}
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return partialState{}
+	}
+	return state
+}
+
+func writePartialState(statePath string, state partialState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONEncoding, err)
+		return constants.ErrJSONEncoding
+	}
+	if err := os.WriteFile(statePath, content, os.FileMode(0644)); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
 	return nil
 }
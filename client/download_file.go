@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+const (
+	defaultDownloadConnections = 4
+	defaultDownloadSegmentSize = 16 << 20
+	defaultDownloadRetries     = 3
+)
+
+// DownloadFileOptions configures DownloadFile. The zero value of each field
+// selects its default.
+type DownloadFileOptions struct {
+	// Connections is the number of segments downloaded concurrently, 4 by
+	// default.
+	Connections int
+	// SegmentSize is the size in bytes of the ranges the file is split into,
+	// 16 MiB by default.
+	SegmentSize int64
+	// Retries is the number of times a failed segment is downloaded again
+	// before giving up, 3 by default. A negative value disables the retries.
+	Retries int
+}
+
+// withDefaults returns a copy of the options with the defaults set.
+func (o *DownloadFileOptions) withDefaults() DownloadFileOptions {
+	var opts DownloadFileOptions
+	if o != nil {
+		opts = *o
+	}
+	if opts.Connections < 1 {
+		opts.Connections = defaultDownloadConnections
+	}
+	if opts.SegmentSize < 1 {
+		opts.SegmentSize = defaultDownloadSegmentSize
+	}
+	if opts.Retries == 0 {
+		opts.Retries = defaultDownloadRetries
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	return opts
+}
+
+// segment is a byte range of a file being downloaded.
+type segment struct {
+	offset int64
+	length int64
+}
+
+// DownloadFile downloads the file at remotePath to localPath, fetching byte
+// ranges of the file over several connections. Each failed segment is retried
+// on its own and the content is verified against the md5 of the node once
+// complete. The content is written to a partial file next to localPath which
+// is renamed to localPath once verified, a failed download leaves localPath
+// as it was. A nil opts uses the defaults.
+func (c *Client) DownloadFile(remotePath, localPath string, opts *DownloadFileOptions) error {
+	return c.DownloadFileContext(context.Background(), remotePath, localPath, opts)
+}
+
+// DownloadFileContext is like DownloadFile but uses ctx for all the requests.
+func (c *Client) DownloadFileContext(ctx context.Context, remotePath, localPath string, opts *DownloadFileOptions) error {
+	log.Debugf("downloading %q to %q", remotePath, localPath)
+
+	n, err := c.GetNodeTree().FindNode(remotePath)
+	if err != nil {
+		return err
+	}
+	if n.IsDir() {
+		log.Errorf("%s: %s", constants.ErrPathIsFolder, remotePath)
+		return constants.ErrPathIsFolder
+	}
+
+	// the segments are not written in order, the partial file cannot be
+	// resumed by resumeDownload.
+	partialPath := localPath + partialSuffix
+	os.Remove(localPath + partialStateSuffix)
+	f, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(0644))
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	if err = c.downloadSegments(ctx, n, f, opts.withDefaults()); err == nil {
+		err = verifyMD5(f, n)
+	}
+	if cerr := f.Close(); err == nil && cerr != nil {
+		log.Errorf("%s: %s", constants.ErrWritingFileContents, cerr)
+		err = constants.ErrWritingFileContents
+	}
+	if err != nil {
+		os.Remove(partialPath)
+		return err
+	}
+	if err := os.Rename(partialPath, localPath); err != nil {
+		os.Remove(partialPath)
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+
+	return nil
+}
+
+// downloadSegments preallocates f to the size of the node and fills it with
+// the content of the node, downloading opts.Connections segments at a time.
+func (c *Client) downloadSegments(ctx context.Context, n *node.Node, f *os.File, opts DownloadFileOptions) error {
+	size := int64(n.Size())
+	if err := f.Truncate(size); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		segments = make(chan segment)
+	)
+	for i := 0; i < opts.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range segments {
+				if err := c.downloadSegment(ctx, n, f, s, opts.Retries); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for offset := int64(0); offset < size; offset += opts.SegmentSize {
+		select {
+		case segments <- segment{offset: offset, length: min(opts.SegmentSize, size-offset)}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(segments)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// downloadSegment writes the segment of the content of the node to f at its
+// offset, downloading it again up to retries times.
+func (c *Client) downloadSegment(ctx context.Context, n *node.Node, f *os.File, s segment, retries int) error {
+	for attempt := 1; ; attempt++ {
+		err := c.copySegment(ctx, n, f, s)
+		if err == nil || attempt > retries || ctx.Err() != nil {
+			return err
+		}
+
+		wait := c.backoff(attempt, nil)
+		log.Infof("retrying the segment %d-%d of %q in %s (attempt %d of %d): %s", s.offset, s.offset+s.length-1, n.Name, wait, attempt+1, retries+1, err)
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) copySegment(ctx context.Context, n *node.Node, f *os.File, s segment) error {
+	body, err := c.GetNodeTree().DownloadRangeContext(ctx, n, s.offset, s.length)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	written, err := io.Copy(io.NewOffsetWriter(f, s.offset), body)
+	if err == nil && written != s.length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrNodeDownload, err)
+		return fmt.Errorf("%w: %w", constants.ErrNodeDownload, err)
+	}
+	return nil
+}
+
+// verifyMD5 checks the content of f against the md5 of the node, if the node
+// has one.
+func verifyMD5(f *os.File, n *node.Node) error {
+	if n.ContentProperties.MD5 == "" {
+		return nil
+	}
+	sum, err := md5Sum(f, n)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrOpenFile, err)
+		return constants.ErrOpenFile
+	}
+	if sum != n.ContentProperties.MD5 {
+		log.Errorf("%s: got %s want %s", constants.ErrMD5Mismatch, sum, n.ContentProperties.MD5)
+		return constants.ErrMD5Mismatch
+	}
+	return nil
+}
+
+// md5Sum returns the hex encoded MD5 of the first n.Size() bytes of f.
+func md5Sum(f *os.File, n *node.Node) (string, error) {
+	hash := md5.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(f, 0, int64(n.Size()))); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+// faultyTransport alters the body of the content responses: the first
+// response of each range is truncated and, if corrupt is set, every byte is
+// flipped.
+type faultyTransport struct {
+	corrupt bool
+
+	mutex     sync.Mutex
+	truncated map[string]bool
+}
+
+func (t *faultyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil || r.Header.Get("Range") == "" {
+		return res, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mutex.Lock()
+	truncate := !t.truncated[r.Header.Get("Range")]
+	t.truncated[r.Header.Get("Range")] = true
+	t.mutex.Unlock()
+	if truncate {
+		body = body[:len(body)/2]
+	}
+	if t.corrupt {
+		for i := range body {
+			body[i] ^= 0xff
+		}
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func TestDownloadFile(t *testing.T) {
+	server := newTestServer(t)
+	content := make([]byte, 100<<10+7)
+	rand.New(rand.NewSource(1)).Read(content)
+	if _, err := server.PutFile("/big.bin", content); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, corrupt := range map[string]bool{"truncated": false, "corrupted": true} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(&Config{
+				CacheFile:    filepath.Join(t.TempDir(), "acd-cache"),
+				EndpointURL:  server.EndpointURL(),
+				RefreshToken: server.RefreshToken,
+				RetryWaitMin: "1ms",
+				RetryWaitMax: "5ms",
+				SyncInterval: "1h",
+				TokenURL:     server.TokenURL(),
+				Transport:    &faultyTransport{corrupt: corrupt, truncated: map[string]bool{}},
+			})
+			if err != nil {
+				t.Fatalf("New() error: %s", err)
+			}
+			defer c.Close()
+
+			local := filepath.Join(t.TempDir(), "big.bin")
+			if err := os.WriteFile(local, []byte("existing"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			requests := server.Requests()
+			err = c.DownloadFile("/big.bin", local, &DownloadFileOptions{Connections: 3, SegmentSize: 16 << 10})
+			if corrupt {
+				if !errors.Is(err, constants.ErrMD5Mismatch) {
+					t.Fatalf("c.DownloadFile(): want %s got %v", constants.ErrMD5Mismatch, err)
+				}
+				if got, err := os.ReadFile(local); err != nil || string(got) != "existing" {
+					t.Errorf("the existing local file was altered: %q (%v)", got, err)
+				}
+				if _, err := os.Stat(local + partialSuffix); !os.IsNotExist(err) {
+					t.Errorf("the partial file was not removed: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("c.DownloadFile() error: %s", err)
+			}
+
+			// 7 segments, each downloaded twice.
+			if want, got := 14, server.Requests()-requests; want != got {
+				t.Errorf("c.DownloadFile() requests: want %d got %d", want, got)
+			}
+			got, err := os.ReadFile(local)
+			if err != nil || !bytes.Equal(content, got) {
+				t.Errorf("downloaded content differs (%v)", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// rangeRecorder records the Range header of the requests.
+type rangeRecorder struct {
+	mutex  sync.Mutex
+	ranges []string
+}
+
+func (t *rangeRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	if value := r.Header.Get("Range"); value != "" {
+		t.mutex.Lock()
+		t.ranges = append(t.ranges, value)
+		t.mutex.Unlock()
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (t *rangeRecorder) reset() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	ranges := t.ranges
+	t.ranges = nil
+	return ranges
+}
+
+func TestDownloadFolderResumes(t *testing.T) {
+	server := newTestServer(t)
+	content := []byte("0123456789abcdefghij")
+	id, err := server.PutFile("/folder/file.txt", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := server.Node(id)
+	recorder := &rangeRecorder{}
+	c, err := New(&Config{
+		CacheFile:    filepath.Join(t.TempDir(), "acd-cache"),
+		EndpointURL:  server.EndpointURL(),
+		RefreshToken: server.RefreshToken,
+		SyncInterval: "1h",
+		TokenURL:     server.TokenURL(),
+		Transport:    recorder,
+	})
+	if err != nil {
+		t.Fatalf("New() error: %s", err)
+	}
+	defer c.Close()
+
+	// an interrupted download of the first 8 bytes.
+	local := t.TempDir()
+	flp := filepath.Join(local, "file.txt")
+	state, _ := json.Marshal(partialState{Id: n.Id, MD5: n.ContentProperties.MD5, Size: n.ContentProperties.Size})
+	os.WriteFile(flp+partialStateSuffix, state, 0644)
+	os.WriteFile(flp+partialSuffix, content[:8], 0644)
+
+	if err := c.DownloadFolder(local, "/folder", false); err != nil {
+		t.Fatalf("c.DownloadFolder() error: %s", err)
+	}
+	if want, got := []string{"bytes=8-"}, recorder.reset(); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("c.DownloadFolder() ranges: want %q got %q", want, got)
+	}
+	if got, err := os.ReadFile(flp); err != nil || string(got) != string(content) {
+		t.Errorf("downloaded content: want %q got %q (%v)", content, got, err)
+	}
+	for _, name := range []string{flp + partialSuffix, flp + partialStateSuffix} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", name, err)
+		}
+	}
+
+	// the file is complete, nothing is downloaded.
+	requests := server.Requests()
+	if err := c.DownloadFolder(local, "/folder", false); err != nil {
+		t.Fatalf("c.DownloadFolder() again error: %s", err)
+	}
+	if want, got := requests, server.Requests(); want != got {
+		t.Errorf("c.DownloadFolder() again: want no requests got %d", got-want)
+	}
+
+	// the state of another content restarts from the first byte.
+	os.Remove(flp)
+	state, _ = json.Marshal(partialState{Id: n.Id, MD5: "stale", Size: n.ContentProperties.Size})
+	os.WriteFile(flp+partialStateSuffix, state, 0644)
+	os.WriteFile(flp+partialSuffix, []byte("stale"), 0644)
+	if err := c.DownloadFolder(local, "/folder", false); err != nil {
+		t.Fatalf("c.DownloadFolder() stale error: %s", err)
+	}
+	if got := recorder.reset(); len(got) != 1 || got[0] != "bytes=0-" {
+		t.Errorf("c.DownloadFolder() stale ranges: want %q got %q", "bytes=0-", got)
+	}
+	if got, err := os.ReadFile(flp); err != nil || string(got) != string(content) {
+		t.Errorf("downloaded content: want %q got %q (%v)", content, got, err)
+	}
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/montaguethomas/acd-go/constants"
@@ -30,7 +31,14 @@ func (c *Client) GetContentURL(path string) string {
 }
 
 func (c *Client) setEndpoints() error {
-	req, err := http.NewRequest("GET", constants.AmazonDriveEndpointURL, nil)
+	c.config.mutex.RLock()
+	endpointURL := c.config.EndpointURL
+	c.config.mutex.RUnlock()
+	if endpointURL == "" {
+		endpointURL = constants.AmazonDriveEndpointURL
+	}
+
+	req, err := http.NewRequest("GET", endpointURL, nil)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return constants.ErrCreatingHTTPRequest
@@ -40,7 +48,10 @@ func (c *Client) setEndpoints() error {
 	res, err := c.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return constants.ErrDoingHTTPRequest
+		return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return err
 	}
 	defer res.Body.Close()
 	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
@@ -20,6 +20,9 @@ func (c *Client) List(path string) (node.Nodes, error) {
 		log.Errorf("%s: %s", constants.ErrPathIsNotFolder, path)
 		return nil, constants.ErrPathIsNotFolder
 	}
+	if err := c.GetNodeTree().LoadChildren(rootNode); err != nil {
+		return nil, err
+	}
 
 	return rootNode.Nodes, nil
 }
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"path"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// Move moves the node at srcPath to dstPath. If dstPath is an existing folder
+// the node is moved into it, otherwise the node is moved under the parent
+// folder of dstPath and renamed to its last element. It fails with
+// constants.ErrFileExists if a node already exists at the destination.
+func (c *Client) Move(srcPath, dstPath string) error {
+	return c.MoveContext(context.Background(), srcPath, dstPath)
+}
+
+// MoveContext is like Move but uses ctx for the requests.
+func (c *Client) MoveContext(ctx context.Context, srcPath, dstPath string) error {
+	log.Debugf("moving %q to %q", srcPath, dstPath)
+
+	nt := c.GetNodeTree()
+	n, err := nt.FindNode(srcPath)
+	if err != nil {
+		return err
+	}
+
+	parentPath, name := path.Dir(path.Clean("/"+dstPath)), path.Base(dstPath)
+	if dst, err := c.GetNodeTree().LookupNode(dstPath); err == nil {
+		if dst == n {
+			return nil
+		}
+		if !dst.IsDir() {
+			log.Errorf("%s: %s", constants.ErrFileExists, dstPath)
+			return constants.ErrFileExists
+		}
+		parentPath, name = dstPath, n.Name
+	}
+	newParent, err := nt.FindNode(parentPath)
+	if err != nil {
+		return err
+	}
+	if !newParent.IsDir() {
+		log.Errorf("%s: %s", constants.ErrPathIsNotFolder, parentPath)
+		return constants.ErrPathIsNotFolder
+	}
+	if existing, err := c.GetNodeTree().LookupNode(path.Join(parentPath, name)); err == nil && existing != n {
+		log.Errorf("%s: %s", constants.ErrFileExists, path.Join(parentPath, name))
+		return constants.ErrFileExists
+	}
+
+	// rename first when the current name is taken in the new parent.
+	if _, err := c.GetNodeTree().LookupNode(path.Join(parentPath, n.Name)); err == nil {
+		if err := nt.RenameContext(ctx, n, name); err != nil {
+			return err
+		}
+		return nt.MoveContext(ctx, n, newParent)
+	}
+	if err := nt.MoveContext(ctx, n, newParent); err != nil {
+		return err
+	}
+	return nt.RenameContext(ctx, n, name)
+}
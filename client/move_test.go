@@ -0,0 +1,66 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+func TestMove(t *testing.T) {
+	server := newTestServer(t)
+	for _, name := range []string{"/a/one.txt", "/a/two.txt", "/b/one.txt", "/c/d/three.txt"} {
+		if _, err := server.PutFile(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestClient(t, server, "")
+
+	tests := []struct {
+		src, dst string
+		want     string
+		err      error
+	}{
+		{src: "/a/two.txt", dst: "/a/renamed.txt", want: "/a/renamed.txt"},
+		{src: "/a/renamed.txt", dst: "/b", want: "/b/renamed.txt"},
+		{src: "/b/renamed.txt", dst: "/c/d/moved.txt", want: "/c/d/moved.txt"},
+		{src: "/a/one.txt", dst: "/b/", err: constants.ErrFileExists},
+		{src: "/a/one.txt", dst: "/c/d/three.txt", err: constants.ErrFileExists},
+		{src: "/a/one.txt", dst: "/b/ONE.TXT", err: constants.ErrFileExists},
+		{src: "/c", dst: "/c/d", err: constants.ErrMoveIntoDescendant},
+		{src: "/a/one.txt", dst: "/missing/one.txt", err: constants.ErrNodeNotFound},
+		// the name is free in the new parent only after the rename.
+		{src: "/b/one.txt", dst: "/a/uno.txt", want: "/a/uno.txt"},
+		{src: "/c/d", dst: "/a/D", want: "/a/D/three.txt"},
+	}
+	for _, test := range tests {
+		n, _ := c.GetNodeTree().FindNode(test.src)
+		err := c.Move(test.src, test.dst)
+		if err != test.err {
+			t.Errorf("c.Move(%q, %q): want %v got %v", test.src, test.dst, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, err := c.GetNodeTree().FindNode(test.src); err != constants.ErrNodeNotFound {
+			t.Errorf("c.Move(%q, %q): the source is still in the tree", test.src, test.dst)
+		}
+		if got, err := c.GetNodeTree().FindById(n.Id); err != nil || got != n {
+			t.Errorf("c.Move(%q, %q): FindById() want the moved node got %v (%v)", test.src, test.dst, got, err)
+		}
+		if _, err := c.GetNodeTree().FindNode(test.want); err != nil {
+			t.Errorf("c.Move(%q, %q): FindNode(%q) error: %s", test.src, test.dst, test.want, err)
+		}
+		if _, ok := server.Lookup(test.want); !ok {
+			t.Errorf("c.Move(%q, %q): %q not found on the server", test.src, test.dst, test.want)
+		}
+	}
+
+	// the tree of another client is consistent after a sync.
+	other := newTestClient(t, server, "")
+	for _, name := range []string{"/a/uno.txt", "/a/d/three.txt", "/a/d/moved.txt"} {
+		if _, err := other.GetNodeTree().FindNode(name); err != nil {
+			t.Errorf("other client, FindNode(%q) error: %s", name, err)
+		}
+	}
+}
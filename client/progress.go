@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sync"
+
+	"github.com/montaguethomas/acd-go/node"
+)
+
+type (
+	// FolderProgress is the state of an UploadFolder or a DownloadFolder.
+	FolderProgress struct {
+		// FilesDone is the number of files transferred, or skipped because
+		// they were already up to date, so far.
+		FilesDone int
+		// FilesTotal is the number of files of the folder.
+		FilesTotal int
+		// File is the progress of the last file which made progress.
+		File node.Progress
+	}
+
+	// FolderProgressFunc receives the progress of a folder transfer. The
+	// calls are serialized, even when files are transferred in parallel, and
+	// should return quickly.
+	FolderProgressFunc func(FolderProgress)
+
+	folderProgressKey struct{}
+)
+
+// WithFolderProgress returns a copy of ctx which makes UploadFolderContext,
+// UploadFolderWithOptionsContext and DownloadFolderContext report their
+// progress to fn. It is reported after every read of a file, and once more
+// when the file is done.
+func WithFolderProgress(ctx context.Context, fn FolderProgressFunc) context.Context {
+	return context.WithValue(ctx, folderProgressKey{}, fn)
+}
+
+// folderProgress aggregates the progress of the files of a folder. A nil
+// *folderProgress reports nothing.
+type folderProgress struct {
+	fn FolderProgressFunc
+
+	mutex    sync.Mutex
+	progress FolderProgress
+}
+
+// newFolderProgress returns the folderProgress of a folder of total files
+// reporting to the FolderProgressFunc of ctx, or nil if ctx has none. The
+// total is only computed if needed.
+func newFolderProgress(ctx context.Context, total func() int) *folderProgress {
+	fn, _ := ctx.Value(folderProgressKey{}).(FolderProgressFunc)
+	if fn == nil {
+		return nil
+	}
+	return &folderProgress{fn: fn, progress: FolderProgress{FilesTotal: total()}}
+}
+
+// fileContext returns a copy of ctx reporting the progress of a file of the
+// folder.
+func (fp *folderProgress) fileContext(ctx context.Context) context.Context {
+	if fp == nil {
+		return ctx
+	}
+	return node.WithProgress(ctx, func(p node.Progress) {
+		fp.mutex.Lock()
+		defer fp.mutex.Unlock()
+		fp.progress.File = p
+		fp.fn(fp.progress)
+	})
+}
+
+// fileDone reports that the file of size bytes is done.
+func (fp *folderProgress) fileDone(name string, size int64) {
+	if fp == nil {
+		return
+	}
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	rate := 0.0
+	if fp.progress.File.Name == name {
+		rate = fp.progress.File.Rate
+	}
+	fp.progress.FilesDone++
+	fp.progress.File = node.Progress{Name: name, Bytes: size, Total: size, Rate: rate}
+	fp.fn(fp.progress)
+}
+
+// countLocalFiles returns the number of files under root, only those directly
+// in root if recursive is false.
+func countLocalFiles(root string, recursive bool) int {
+	count := 0
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if !recursive && p != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		count++
+		return nil
+	})
+	return count
+}
+
+// countRemoteFiles returns the number of files under n, only its children if
+// recursive is false.
+func (c *Client) countRemoteFiles(ctx context.Context, n *node.Node, recursive bool) int {
+	if err := c.GetNodeTree().LoadChildrenContext(ctx, n); err != nil {
+		return 0
+	}
+	n.RLock()
+	children := make([]*node.Node, 0, len(n.Nodes))
+	for _, child := range n.Nodes {
+		children = append(children, child)
+	}
+	n.RUnlock()
+
+	count := 0
+	for _, child := range children {
+		switch {
+		case !child.IsDir():
+			count++
+		case recursive:
+			count += c.countRemoteFiles(ctx, child, recursive)
+		}
+	}
+	return count
+}
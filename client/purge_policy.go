@@ -0,0 +1,185 @@
+package client
+
+import (
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+// PurgeTrashPolicy selects the nodes of the trash purged by PurgeTrash. The
+// paths are the original paths of the nodes, before they were trashed, and the
+// patterns use the syntax of path.Match. A path pattern also matches all the
+// descendants of the paths it matches, so "/photos" matches "/photos/a.jpg".
+// A trashed folder is purged with all of its descendants, so it is only purged
+// if the policy selects every one of them.
+type PurgeTrashPolicy struct {
+	// MinAge is how long a node must have been in the trash, based on its
+	// ModifiedDate, before it is purged. e.g. "720h" for 30 days.
+	MinAge string `json:"minAge"`
+
+	// IncludePaths, if set, restricts the purge to the nodes whose original
+	// path matches one of the patterns.
+	IncludePaths []string `json:"includePaths"`
+
+	// ExcludePaths protects the nodes whose original path matches one of the
+	// patterns from being purged.
+	ExcludePaths []string `json:"excludePaths"`
+
+	// IncludeLabels, if set, restricts the purge to the nodes having a label
+	// matching one of the patterns.
+	IncludeLabels []string `json:"includeLabels"`
+
+	// ExcludeLabels protects the nodes having a label matching one of the
+	// patterns from being purged.
+	ExcludeLabels []string `json:"excludeLabels"`
+
+	// MaxBytes is the maximum number of bytes purged per run, the oldest nodes
+	// being purged first. The size of a trashed folder is the size of all of
+	// its descendants. 0 means no limit.
+	MaxBytes uint64 `json:"maxBytes"`
+}
+
+// validate returns an error if MinAge or one of the patterns is invalid.
+func (p *PurgeTrashPolicy) validate() error {
+	if _, err := p.minAge(); err != nil {
+		return err
+	}
+	for _, patterns := range [][]string{p.IncludePaths, p.ExcludePaths, p.IncludeLabels, p.ExcludeLabels} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *PurgeTrashPolicy) minAge() (time.Duration, error) {
+	if p.MinAge == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(p.MinAge)
+}
+
+// selectNodes returns the nodes of the trash to purge at now, oldest first.
+// originalPath returns the path of a node before it was trashed and
+// descendants the nodes under a trashed folder, which are purged with it. A
+// folder is only purged if all of its descendants are selected too, its size
+// being the size of its descendants, and the nodes under a purged folder are
+// not returned.
+func (p *PurgeTrashPolicy) selectNodes(trash []*node.Node, now time.Time, originalPath func(*node.Node) string, descendants func(*node.Node) []*node.Node) ([]*node.Node, error) {
+	minAge, err := p.minAge()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []*node.Node
+	for _, n := range trash {
+		nodePath := originalPath(n)
+		if !p.match(n, now, minAge, nodePath) {
+			continue
+		}
+		if n.IsDir() && slices.ContainsFunc(descendants(n), func(d *node.Node) bool {
+			return !p.match(d, now, minAge, originalPath(d))
+		}) {
+			log.Debugf("not purging %s: a descendant is not selected", nodePath)
+			continue
+		}
+		selected = append(selected, n)
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].ModifiedDate.Before(selected[j].ModifiedDate)
+	})
+
+	var total uint64
+	purged := make(map[string]bool)
+	limited := selected[:0]
+	for _, n := range selected {
+		if purged[n.Id] {
+			continue
+		}
+		// the descendants already purged are not counted twice.
+		size := n.ContentProperties.Size
+		var nested []*node.Node
+		if n.IsDir() {
+			nested = descendants(n)
+		}
+		for _, d := range nested {
+			if !purged[d.Id] {
+				size += d.ContentProperties.Size
+			}
+		}
+		if p.MaxBytes > 0 && total+size > p.MaxBytes {
+			continue
+		}
+		total += size
+		purged[n.Id] = true
+		for _, d := range nested {
+			purged[d.Id] = true
+		}
+		limited = append(limited, n)
+	}
+	return limited, nil
+}
+
+// match returns whether the policy selects the node whose original path is
+// nodePath, leaving its descendants aside.
+func (p *PurgeTrashPolicy) match(n *node.Node, now time.Time, minAge time.Duration, nodePath string) bool {
+	if now.Sub(n.ModifiedDate) < minAge {
+		return false
+	}
+	// a node whose original path is unknown may be under an excluded path.
+	if nodePath == "" && (len(p.IncludePaths) > 0 || len(p.ExcludePaths) > 0) {
+		log.Debugf("not purging %s: unknown original path", n.Id)
+		return false
+	}
+	if len(p.IncludePaths) > 0 && !matchPath(p.IncludePaths, nodePath) {
+		return false
+	}
+	if matchPath(p.ExcludePaths, nodePath) {
+		log.Debugf("not purging %s: excluded path", nodePath)
+		return false
+	}
+	if len(p.IncludeLabels) > 0 && !matchLabels(p.IncludeLabels, n.Labels) {
+		return false
+	}
+	if matchLabels(p.ExcludeLabels, n.Labels) {
+		log.Debugf("not purging %s: excluded label", nodePath)
+		return false
+	}
+	return true
+}
+
+// matchPath returns whether the path, or one of its parents, matches one of
+// the patterns.
+func matchPath(patterns []string, p string) bool {
+	if p == "" {
+		return false
+	}
+	for ; ; p = path.Dir(p) {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+		if p == "/" || p == "." {
+			return false
+		}
+	}
+}
+
+// matchLabels returns whether one of the labels matches one of the patterns.
+func matchLabels(patterns []string, labels []string) bool {
+	for _, label := range labels {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, label); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
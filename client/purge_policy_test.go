@@ -0,0 +1,152 @@
+package client
+
+import (
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/montaguethomas/acd-go/node"
+)
+
+func TestPurgeTrashPolicySelectNodes(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	newTrashed := func(p string, age time.Duration, size uint64, labels ...string) *node.Node {
+		return &node.Node{
+			Id:                p,
+			Name:              path.Base(p),
+			ModifiedDate:      now.Add(-age),
+			Labels:            labels,
+			ContentProperties: node.ContentProperties{Size: size},
+		}
+	}
+	day := 24 * time.Hour
+	trash := []*node.Node{
+		newTrashed("/docs/today.txt", time.Hour, 10),
+		newTrashed("/docs/old.txt", 40*day, 10),
+		newTrashed("/docs/older.txt", 50*day, 20),
+		newTrashed("/photos/2024/a.jpg", 60*day, 30),
+		newTrashed("/keep/me.txt", 70*day, 10, "keep"),
+	}
+	originalPath := func(n *node.Node) string { return n.Id }
+
+	tests := map[string]struct {
+		policy PurgeTrashPolicy
+		want   []string
+	}{
+		"everything": {
+			want: []string{"/keep/me.txt", "/photos/2024/a.jpg", "/docs/older.txt", "/docs/old.txt", "/docs/today.txt"},
+		},
+		"min age": {
+			policy: PurgeTrashPolicy{MinAge: "720h"},
+			want:   []string{"/keep/me.txt", "/photos/2024/a.jpg", "/docs/older.txt", "/docs/old.txt"},
+		},
+		"include paths": {
+			policy: PurgeTrashPolicy{IncludePaths: []string{"/docs/o*"}},
+			want:   []string{"/docs/older.txt", "/docs/old.txt"},
+		},
+		"exclude parent path": {
+			policy: PurgeTrashPolicy{ExcludePaths: []string{"/photos", "/docs"}},
+			want:   []string{"/keep/me.txt"},
+		},
+		"labels": {
+			policy: PurgeTrashPolicy{MinAge: "720h", ExcludeLabels: []string{"ke*"}},
+			want:   []string{"/photos/2024/a.jpg", "/docs/older.txt", "/docs/old.txt"},
+		},
+		"include labels": {
+			policy: PurgeTrashPolicy{IncludeLabels: []string{"keep"}},
+			want:   []string{"/keep/me.txt"},
+		},
+		"max bytes": {
+			policy: PurgeTrashPolicy{MaxBytes: 45},
+			want:   []string{"/keep/me.txt", "/photos/2024/a.jpg"},
+		},
+	}
+	for name, test := range tests {
+		selected, err := test.policy.selectNodes(trash, now, originalPath, func(*node.Node) []*node.Node { return nil })
+		if err != nil {
+			t.Errorf("%s: selectNodes() error: %s", name, err)
+			continue
+		}
+		var got []string
+		for _, n := range selected {
+			got = append(got, n.Id)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: selectNodes(): want %q got %q", name, test.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: selectNodes(): want %q got %q", name, test.want, got)
+				break
+			}
+		}
+	}
+
+	// a node whose original path is unknown is only selected without a path
+	// filter.
+	unknownPath := func(*node.Node) string { return "" }
+	for _, policy := range []PurgeTrashPolicy{{ExcludePaths: []string{"/keep"}}, {IncludePaths: []string{"/*"}}} {
+		if selected, _ := policy.selectNodes(trash, now, unknownPath, func(*node.Node) []*node.Node { return nil }); len(selected) != 0 {
+			t.Errorf("selectNodes() unknown paths with %+v: want none got %d nodes", policy, len(selected))
+		}
+	}
+	if selected, _ := (&PurgeTrashPolicy{}).selectNodes(trash, now, unknownPath, func(*node.Node) []*node.Node { return nil }); len(selected) != len(trash) {
+		t.Errorf("selectNodes() unknown paths without a path filter: want %d nodes got %d", len(trash), len(selected))
+	}
+
+	if err := (&PurgeTrashPolicy{ExcludePaths: []string{"["}}).validate(); err != path.ErrBadPattern {
+		t.Errorf("validate() invalid pattern: want %s got %v", path.ErrBadPattern, err)
+	}
+}
+
+func TestPurgeTrashPolicySelectFolders(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	newTrashed := func(p string, kind node.NodeKind, age time.Duration, size uint64, labels ...string) *node.Node {
+		return &node.Node{
+			Id:                p,
+			Name:              path.Base(p),
+			Kind:              kind,
+			ModifiedDate:      now.Add(-age),
+			Labels:            labels,
+			ContentProperties: node.ContentProperties{Size: size},
+		}
+	}
+	day := 24 * time.Hour
+	albums := newTrashed("/albums", node.KindFolder, 60*day, 0)
+	music := newTrashed("/music", node.KindFolder, 50*day, 0)
+	// a file trashed after its folder is listed by the trash too.
+	recent := newTrashed("/albums/y.jpg", node.KindFile, time.Hour, 20)
+	trash := []*node.Node{albums, music, recent}
+	descendants := map[string][]*node.Node{
+		"/albums": {newTrashed("/albums/keep", node.KindFolder, 70*day, 0), newTrashed("/albums/keep/x.jpg", node.KindFile, 70*day, 10, "keep"), recent},
+		"/music":  {newTrashed("/music/z.mp3", node.KindFile, 80*day, 40)},
+	}
+	originalPath := func(n *node.Node) string { return n.Id }
+
+	tests := map[string]struct {
+		policy PurgeTrashPolicy
+		want   []string
+	}{
+		"everything":         {want: []string{"/albums", "/music"}},
+		"excluded path":      {PurgeTrashPolicy{ExcludePaths: []string{"/albums/keep"}}, []string{"/music", "/albums/y.jpg"}},
+		"excluded label":     {PurgeTrashPolicy{ExcludeLabels: []string{"keep"}}, []string{"/music", "/albums/y.jpg"}},
+		"recent descendant":  {PurgeTrashPolicy{MinAge: "720h"}, []string{"/music"}},
+		"size of the folder": {PurgeTrashPolicy{MaxBytes: 35}, []string{"/albums"}},
+	}
+	for name, test := range tests {
+		selected, err := test.policy.selectNodes(trash, now, originalPath, func(n *node.Node) []*node.Node { return descendants[n.Id] })
+		if err != nil {
+			t.Errorf("%s: selectNodes() error: %s", name, err)
+			continue
+		}
+		var got []string
+		for _, n := range selected {
+			got = append(got, n.Id)
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: selectNodes(): want %q got %q", name, test.want, got)
+		}
+	}
+}
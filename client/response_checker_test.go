@@ -0,0 +1,91 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+func TestCheckResponse(t *testing.T) {
+	tests := map[int]error{
+		http.StatusBadRequest:          constants.ErrResponseBadInput,
+		http.StatusUnauthorized:        constants.ErrResponseInvalidToken,
+		http.StatusForbidden:           constants.ErrResponseForbidden,
+		http.StatusNotFound:            constants.ErrResponseNotFound,
+		http.StatusConflict:            constants.ErrResponseDuplicateExists,
+		http.StatusTooManyRequests:     constants.ErrResponseTooManyRequests,
+		http.StatusInternalServerError: constants.ErrResponseInternalServerError,
+		http.StatusServiceUnavailable:  constants.ErrResponseUnavailable,
+		http.StatusTeapot:              constants.ErrResponseUnknown,
+	}
+
+	c := &Client{}
+	for status, want := range tests {
+		req := httptest.NewRequest("POST", "https://drive.example.com/drive/v1/nodes", nil)
+		res := &http.Response{
+			Status:     http.StatusText(status),
+			StatusCode: status,
+			Header:     http.Header{"X-Amzn-Requestid": {"req-1234"}},
+			Body:       io.NopCloser(strings.NewReader(`{"code":"NAME_ALREADY_EXISTS","message":"Node with the name foo already exists","logref":"log-1"}`)),
+			Request:    req,
+		}
+
+		err := c.CheckResponse(res)
+		if !errors.Is(err, want) {
+			t.Errorf("c.CheckResponse(%d): want errors.Is %q got %v", status, want, err)
+		}
+		var apiErr *constants.APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("c.CheckResponse(%d): want *constants.APIError got %T", status, err)
+		}
+		if want, got := status, apiErr.StatusCode; want != got {
+			t.Errorf("c.CheckResponse(%d).StatusCode: want %d got %d", status, want, got)
+		}
+		if want, got := "POST", apiErr.Method; want != got {
+			t.Errorf("c.CheckResponse(%d).Method: want %s got %s", status, want, got)
+		}
+		if want, got := "NAME_ALREADY_EXISTS", apiErr.Code; want != got {
+			t.Errorf("c.CheckResponse(%d).Code: want %s got %s", status, want, got)
+		}
+		if want, got := "Node with the name foo already exists", apiErr.Message; want != got {
+			t.Errorf("c.CheckResponse(%d).Message: want %s got %s", status, want, got)
+		}
+		if want, got := "req-1234", apiErr.RequestId; want != got {
+			t.Errorf("c.CheckResponse(%d).RequestId: want %s got %s", status, want, got)
+		}
+	}
+}
+
+func TestCheckResponseUnknown(t *testing.T) {
+	// 404 and 429 had no error of their own before, they still match
+	// constants.ErrResponseUnknown.
+	tests := map[int]bool{
+		http.StatusNotFound:        true,
+		http.StatusTooManyRequests: true,
+		http.StatusTeapot:          true,
+		http.StatusConflict:        false,
+	}
+
+	c := &Client{}
+	for status, want := range tests {
+		res := &http.Response{StatusCode: status, Body: http.NoBody}
+		if got := errors.Is(c.CheckResponse(res), constants.ErrResponseUnknown); want != got {
+			t.Errorf("c.CheckResponse(%d): want errors.Is ErrResponseUnknown %t got %t", status, want, got)
+		}
+	}
+}
+
+func TestCheckResponseSuccess(t *testing.T) {
+	c := &Client{}
+	for _, status := range []int{http.StatusOK, http.StatusCreated, http.StatusPartialContent} {
+		res := &http.Response{StatusCode: status, Body: http.NoBody}
+		if err := c.CheckResponse(res); err != nil {
+			t.Errorf("c.CheckResponse(%d): want nil got %s", status, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryableStatus are the response status codes worth sending the request
+// again for.
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// isReplayable returns true if the body of the request can be sent again.
+func isReplayable(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+}
+
+// isIdempotent returns true if the request can be applied more than once
+// without changing the outcome.
+func isIdempotent(r *http.Request) bool {
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry returns true if the request has failed with a transient error.
+// Transport errors and server errors are only retried for idempotent requests
+// as the server might have already processed a non-idempotent one. Those are
+// only retried when the server tells it has not: on 429, or on 503 with a
+// Retry-After header.
+func shouldRetry(r *http.Request, res *http.Response, err error) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return isIdempotent(r)
+	}
+	if !retryableStatus[res.StatusCode] {
+		return false
+	}
+	if isIdempotent(r) {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return res.Header.Get("Retry-After") != ""
+	}
+	return false
+}
+
+// rewindBody resets the body of the request so it can be sent again.
+func rewindBody(r *http.Request) error {
+	if r.GetBody == nil {
+		return nil
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	return nil
+}
+
+// backoff returns how long to wait before the next attempt. It honors the
+// Retry-After header of the response if any, up to retryWaitMax, otherwise it
+// is an exponential backoff with jitter between retryWaitMin and retryWaitMax.
+func (c *Client) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if wait, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			if c.retryWaitMax > 0 && wait > c.retryWaitMax {
+				wait = c.retryWaitMax
+			}
+			return wait
+		}
+	}
+
+	wait := c.retryWaitMin << (attempt - 1)
+	if wait <= 0 || (c.retryWaitMax > 0 && wait > c.retryWaitMax) {
+		wait = c.retryWaitMax
+	}
+	if wait <= 0 {
+		return 0
+	}
+	// equal jitter: never less than half of the computed backoff.
+	half := wait / 2
+	return half + rand.N(half+1)
+}
+
+// maxRetryAfter bounds the number of seconds of a Retry-After header so it
+// fits in a time.Duration.
+const maxRetryAfter = int(math.MaxInt64 / time.Second)
+
+// parseRetryAfter parses the value of a Retry-After header which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		seconds = min(seconds, maxRetryAfter)
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// drainBody reads what's left of the response body, so the connection can be
+// reused, and closes it.
+func drainBody(res *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+	res.Body.Close()
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
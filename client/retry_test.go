@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRetryTestClient(handler http.HandlerFunc, maxAttempts int) (*Client, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	c := &Client{
+		config: &Config{
+			Headers:          map[string]string{},
+			RetryMaxAttempts: maxAttempts,
+		},
+		httpClient:   server.Client(),
+		retryWaitMin: time.Millisecond,
+		retryWaitMax: 5 * time.Millisecond,
+	}
+	return c, server
+}
+
+func TestDoRetriesTransientErrors(t *testing.T) {
+	var attempts int32
+	c, server := newRetryTestClient(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d: want body %q got %q", atomic.LoadInt32(&attempts)+1, "payload", body)
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			// the POST requests are only retried when the server tells they
+			// were not processed.
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}, 3)
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, bytes.NewBufferString("payload"))
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("c.Do() error: %s", err)
+	}
+	res.Body.Close()
+	if want, got := http.StatusOK, res.StatusCode; want != got {
+		t.Errorf("c.Do().StatusCode: want %d got %d", want, got)
+	}
+	if want, got := int32(3), atomic.LoadInt32(&attempts); want != got {
+		t.Errorf("attempts: want %d got %d", want, got)
+	}
+}
+
+func TestDoGivesUpAfterMaxAttempts(t *testing.T) {
+	var attempts int32
+	c, server := newRetryTestClient(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}, 2)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("c.Do() error: %s", err)
+	}
+	res.Body.Close()
+	if want, got := http.StatusTooManyRequests, res.StatusCode; want != got {
+		t.Errorf("c.Do().StatusCode: want %d got %d", want, got)
+	}
+	if want, got := int32(2), atomic.LoadInt32(&attempts); want != got {
+		t.Errorf("attempts: want %d got %d", want, got)
+	}
+}
+
+func TestDoRetriesPOSTOnlyIfNotProcessed(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		want       int32
+	}{
+		{http.StatusInternalServerError, "", 1},
+		{http.StatusBadGateway, "", 1},
+		{http.StatusServiceUnavailable, "", 1},
+		{http.StatusServiceUnavailable, "0", 3},
+		{http.StatusTooManyRequests, "", 3},
+	}
+	for _, test := range tests {
+		var attempts int32
+		c, server := newRetryTestClient(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			if test.retryAfter != "" {
+				w.Header().Set("Retry-After", test.retryAfter)
+			}
+			w.WriteHeader(test.status)
+		}, 3)
+
+		req, _ := http.NewRequest("POST", server.URL, bytes.NewBufferString("payload"))
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%d %q: c.Do() error: %s", test.status, test.retryAfter, err)
+		}
+		res.Body.Close()
+		server.Close()
+		if got := atomic.LoadInt32(&attempts); test.want != got {
+			t.Errorf("%d %q: attempts: want %d got %d", test.status, test.retryAfter, test.want, got)
+		}
+	}
+}
+
+func TestBackoffCapsRetryAfter(t *testing.T) {
+	c := &Client{retryWaitMin: time.Millisecond, retryWaitMax: 5 * time.Millisecond}
+	for _, value := range []string{"86400", "9223372036854775807"} {
+		res := &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+		if got := c.backoff(1, res); got != c.retryWaitMax {
+			t.Errorf("c.backoff() with Retry-After %q: want %s got %s", value, c.retryWaitMax, got)
+		}
+	}
+}
+
+func TestDoDoesNotRetryStreamedBodies(t *testing.T) {
+	var attempts int32
+	c, server := newRetryTestClient(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}, 3)
+	defer server.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("streamed"))
+		pw.Close()
+	}()
+	req, _ := http.NewRequest("PUT", server.URL, pr)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("c.Do() error: %s", err)
+	}
+	res.Body.Close()
+	if want, got := int32(1), atomic.LoadInt32(&attempts); want != got {
+		t.Errorf("attempts: want %d got %d", want, got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"0":   0,
+		"5":   5 * time.Second,
+		"120": 2 * time.Minute,
+	}
+	for value, want := range tests {
+		got, ok := parseRetryAfter(value)
+		if !ok || got != want {
+			t.Errorf("parseRetryAfter(%q): want %s got %s (ok %t)", value, want, got, ok)
+		}
+	}
+
+	if got, ok := parseRetryAfter("9223372036854775807"); !ok || got <= 0 {
+		t.Errorf("parseRetryAfter(%q): want the longest wait got %s (ok %t)", "9223372036854775807", got, ok)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(date); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%q): want ~1h got %s (ok %t)", date, got, ok)
+	}
+
+	for _, value := range []string{"", "-1", "soon", strings.Repeat("9", 40)} {
+		if _, ok := parseRetryAfter(value); ok {
+			t.Errorf("parseRetryAfter(%q): want not ok", value)
+		}
+	}
+}
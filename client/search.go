@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+// searchDateLayout is the layout of the dates of the ranges of a SearchFilter.
+const searchDateLayout = "2006-01-02T15:04:05.000Z"
+
+// searchSpecialChars are the characters escaped in the values of a
+// SearchFilter.
+const searchSpecialChars = `+-&|!(){}[]^'"~*?:\ `
+
+// SearchFilter is an expression of the filters query language of the nodes
+// endpoint, built with the Search functions and combined with And and Or. The
+// zero value selects every node.
+type SearchFilter struct {
+	expr string
+	// op is the operator of the expression, AND or OR, empty for a single
+	// term. It is grouped in parentheses when combined with the other one.
+	op string
+}
+
+// SearchKind selects the nodes of the kind.
+func SearchKind(kind node.NodeKind) SearchFilter {
+	return searchTerm("kind", string(kind))
+}
+
+// SearchName selects the nodes named name.
+func SearchName(name string) SearchFilter {
+	return searchTerm("name", name)
+}
+
+// SearchNamePrefix selects the nodes whose name starts with prefix.
+func SearchNamePrefix(prefix string) SearchFilter {
+	return searchPrefix("name", prefix)
+}
+
+// SearchLabel selects the nodes with the label.
+func SearchLabel(label string) SearchFilter {
+	return searchTerm("labels", label)
+}
+
+// SearchParent selects the children of the folder with the Id.
+func SearchParent(id string) SearchFilter {
+	return searchTerm("parents", id)
+}
+
+// SearchStatus selects the nodes with the status.
+func SearchStatus(status node.NodeStatus) SearchFilter {
+	return searchTerm("status", string(status))
+}
+
+// SearchContentType selects the files of the content type.
+func SearchContentType(contentType string) SearchFilter {
+	return searchTerm("contentProperties.contentType", contentType)
+}
+
+// SearchContentTypePrefix selects the files whose content type starts with
+// prefix, such as "image/".
+func SearchContentTypePrefix(prefix string) SearchFilter {
+	return searchPrefix("contentProperties.contentType", prefix)
+}
+
+// SearchExtension selects the files with the extension, without the leading
+// dot.
+func SearchExtension(extension string) SearchFilter {
+	return searchTerm("contentProperties.extension", strings.TrimPrefix(extension, "."))
+}
+
+// SearchMD5 selects the files whose content has the MD5 checksum.
+func SearchMD5(md5 string) SearchFilter {
+	return searchTerm("contentProperties.md5", md5)
+}
+
+// SearchSize selects the files whose size in bytes is between min and max,
+// both included. A max of 0 leaves the range open.
+func SearchSize(min, max uint64) SearchFilter {
+	upper := "*"
+	if max > 0 {
+		upper = strconv.FormatUint(max, 10)
+	}
+	return searchRange("contentProperties.size", strconv.FormatUint(min, 10), upper)
+}
+
+// SearchModifiedDate selects the nodes modified between after and before,
+// both included. A zero date leaves the range open.
+func SearchModifiedDate(after, before time.Time) SearchFilter {
+	return searchDateRange("modifiedDate", after, before)
+}
+
+// SearchCreatedDate selects the nodes created between after and before, both
+// included. A zero date leaves the range open.
+func SearchCreatedDate(after, before time.Time) SearchFilter {
+	return searchDateRange("createdDate", after, before)
+}
+
+// SearchContentDate selects the files whose content is dated between after
+// and before, both included. A zero date leaves the range open.
+func SearchContentDate(after, before time.Time) SearchFilter {
+	return searchDateRange("contentProperties.contentDate", after, before)
+}
+
+// And returns the filter selecting the nodes selected by f and by all the
+// others.
+func (f SearchFilter) And(others ...SearchFilter) SearchFilter {
+	var filters []SearchFilter
+	for _, filter := range append([]SearchFilter{f}, others...) {
+		if filter.expr != "" {
+			filters = append(filters, filter)
+		}
+	}
+	return combineSearchFilters("AND", filters)
+}
+
+// Or returns the filter selecting the nodes selected by f or by one of the
+// others. As the zero value selects every node, so does an Or with it.
+func (f SearchFilter) Or(others ...SearchFilter) SearchFilter {
+	filters := append([]SearchFilter{f}, others...)
+	for _, filter := range filters {
+		if filter.expr == "" {
+			return SearchFilter{}
+		}
+	}
+	return combineSearchFilters("OR", filters)
+}
+
+// String returns the expression of the filter, the filters parameter of the
+// request.
+func (f SearchFilter) String() string {
+	return f.expr
+}
+
+// combineSearchFilters joins the filters with the operator op, grouping the
+// filters joined by the other operator.
+func combineSearchFilters(op string, filters []SearchFilter) SearchFilter {
+	switch len(filters) {
+	case 0:
+		return SearchFilter{}
+	case 1:
+		return filters[0]
+	}
+	exprs := make([]string, len(filters))
+	for i, filter := range filters {
+		if filter.op != "" && filter.op != op {
+			exprs[i] = "(" + filter.expr + ")"
+		} else {
+			exprs[i] = filter.expr
+		}
+	}
+	return SearchFilter{expr: strings.Join(exprs, " "+op+" "), op: op}
+}
+
+func searchTerm(field, value string) SearchFilter {
+	return SearchFilter{expr: field + ":" + escapeSearchValue(value)}
+}
+
+func searchPrefix(field, prefix string) SearchFilter {
+	return SearchFilter{expr: field + ":" + escapeSearchValue(prefix) + "*"}
+}
+
+func searchRange(field, lower, upper string) SearchFilter {
+	return SearchFilter{expr: fmt.Sprintf("%s:[%s TO %s]", field, lower, upper)}
+}
+
+func searchDateRange(field string, after, before time.Time) SearchFilter {
+	lower, upper := "*", "*"
+	if !after.IsZero() {
+		lower = after.UTC().Format(searchDateLayout)
+	}
+	if !before.IsZero() {
+		upper = before.UTC().Format(searchDateLayout)
+	}
+	return searchRange(field, lower, upper)
+}
+
+// escapeSearchValue escapes the special characters of the value with a
+// backslash.
+func escapeSearchValue(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(searchSpecialChars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SearchNodes returns the nodes selected by the filter, as known by Amazon
+// rather than by the node tree. The nodes are not added to the tree.
+func (c *Client) SearchNodes(filter SearchFilter) ([]*node.Node, error) {
+	return c.SearchNodesContext(context.Background(), filter)
+}
+
+// SearchNodesContext is like SearchNodes but uses ctx for the requests.
+func (c *Client) SearchNodesContext(ctx context.Context, filter SearchFilter) ([]*node.Node, error) {
+	var nodes []*node.Node
+	err := c.SearchNodesFunc(ctx, filter, func(n *node.Node) error {
+		nodes = append(nodes, n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// SearchNodesFunc calls fn with the nodes selected by the filter, requesting
+// the next page of nodes once fn has been called with the previous one. An
+// error returned by fn stops the search and is returned.
+func (c *Client) SearchNodesFunc(ctx context.Context, filter SearchFilter, fn func(*node.Node) error) error {
+	log.Debug("client.SearchNodes starting.")
+	defer log.Debug("client.SearchNodes completed.")
+
+	v := url.Values{}
+	if filter.expr != "" {
+		v.Set("filters", filter.expr)
+	}
+	return node.ListNodes(ctx, c, "nodes", v, func(page []*node.Node) error {
+		for _, n := range page {
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/montaguethomas/acd-go/node"
+)
+
+func TestSearchFilter(t *testing.T) {
+	day := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	tests := map[string]struct {
+		filter SearchFilter
+		want   string
+	}{
+		"zero":        {SearchFilter{}, ""},
+		"kind":        {SearchKind(node.KindFile), "kind:FILE"},
+		"escaped":     {SearchName("a (b):c*.txt"), `name:a\ \(b\)\:c\*.txt`},
+		"prefix":      {SearchNamePrefix("IMG 1"), `name:IMG\ 1*`},
+		"extension":   {SearchExtension(".jpg"), "contentProperties.extension:jpg"},
+		"size":        {SearchSize(10, 0), "contentProperties.size:[10 TO *]"},
+		"dates":       {SearchModifiedDate(day, time.Time{}), "modifiedDate:[2024-01-02T02:04:05.000Z TO *]"},
+		"and":         {SearchKind(node.KindFile).And(SearchLabel("PHOTOS"), SearchFilter{}), "kind:FILE AND labels:PHOTOS"},
+		"or":          {SearchExtension("jpg").Or(SearchExtension("png")), "contentProperties.extension:jpg OR contentProperties.extension:png"},
+		"or with all": {SearchExtension("jpg").Or(SearchFilter{}), ""},
+		"grouped": {
+			SearchKind(node.KindFile).And(SearchExtension("jpg").Or(SearchExtension("png")), SearchParent("p")),
+			"kind:FILE AND (contentProperties.extension:jpg OR contentProperties.extension:png) AND parents:p",
+		},
+		"nested": {
+			SearchKind(node.KindFolder).Or(SearchKind(node.KindFile).And(SearchSize(0, 5))),
+			"kind:FOLDER OR (kind:FILE AND contentProperties.size:[0 TO 5])",
+		},
+	}
+	for name, test := range tests {
+		if got := test.filter.String(); test.want != got {
+			t.Errorf("%s: want %q got %q", name, test.want, got)
+		}
+	}
+}
+
+func TestSearchNodes(t *testing.T) {
+	server := newTestServer(t)
+	photosId, err := server.MkdirAll("/photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// more files than a page of results.
+	for i := 0; i < 210; i++ {
+		if _, err := server.PutFile(fmt.Sprintf("/photos/IMG %03d.jpg", i), []byte("photo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/photos/cover (1).png", "/docs/a.txt", "/docs/big.txt"} {
+		if _, err := server.PutFile(name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := server.Trash("/docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, server, "")
+
+	search := func(filter SearchFilter) []string {
+		t.Helper()
+		nodes, err := c.SearchNodes(filter)
+		if err != nil {
+			t.Fatalf("c.SearchNodes(%s) error: %s", filter, err)
+		}
+		var names []string
+		for _, n := range nodes {
+			names = append(names, n.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if got := search(SearchKind(node.KindFile).And(SearchParent(photosId))); len(got) != 211 {
+		t.Errorf("c.SearchNodes() the photos: want 211 nodes got %d", len(got))
+	}
+	if want, got := []string{"IMG 007.jpg"}, search(SearchName("img 007.jpg")); fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("c.SearchNodes() by name: want %q got %q", want, got)
+	}
+	if want, got := []string{"cover (1).png"}, search(SearchNamePrefix("cover (")); fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("c.SearchNodes() by prefix: want %q got %q", want, got)
+	}
+	filter := SearchKind(node.KindFile).And(
+		SearchExtension("txt").Or(SearchExtension("png")),
+		SearchStatus(node.StatusAvailable),
+		SearchSize(10, 0),
+	)
+	if want, got := []string{"big.txt", "cover (1).png"}, search(filter); fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("c.SearchNodes(%s): want %q got %q", filter, want, got)
+	}
+	if want, got := []string{"a.txt"}, search(SearchStatus(node.StatusTrash)); fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("c.SearchNodes() the trash: want %q got %q", want, got)
+	}
+	if got := search(SearchModifiedDate(time.Now().Add(time.Hour), time.Time{})); len(got) != 0 {
+		t.Errorf("c.SearchNodes() modified in the future: want no nodes got %q", got)
+	}
+
+	stop := errors.New("stop")
+	var count int
+	err = c.SearchNodesFunc(context.Background(), SearchNamePrefix("IMG"), func(n *node.Node) error {
+		if count++; count == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 3 {
+		t.Errorf("c.SearchNodesFunc() stopped: want %s after 3 nodes got %v after %d", stop, err, count)
+	}
+}
@@ -0,0 +1,505 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+// ConflictPolicy decides how SyncFolder resolves a file changed on both sides
+// since the last sync.
+type ConflictPolicy string
+
+const (
+	// ConflictNewerWins keeps the most recently modified side. A deleted side
+	// always loses against an edited one.
+	ConflictNewerWins ConflictPolicy = "newer"
+	// ConflictKeepBoth keeps both versions: the local file is renamed with
+	// the conflict suffix and uploaded, and the remote file is downloaded in
+	// its place. A deleted side always loses against an edited one.
+	ConflictKeepBoth ConflictPolicy = "keep-both"
+	// ConflictPreferLocal applies the local side to the remote.
+	ConflictPreferLocal ConflictPolicy = "local"
+	// ConflictPreferRemote applies the remote side to the local.
+	ConflictPreferRemote ConflictPolicy = "remote"
+)
+
+// defaultSyncStateFile is the name of the state file, in the local folder,
+// when SyncFolderOptions.StateFile is not set.
+const defaultSyncStateFile = ".acd-sync.json"
+
+type (
+	// SyncFolderOptions configures SyncFolder. The zero value of each field
+	// selects its default.
+	SyncFolderOptions struct {
+		// StateFile is where the state of the last sync is persisted, a
+		// .acd-sync.json file in the local folder by default. It is never
+		// synced.
+		StateFile string
+		// Conflict is the conflict policy, ConflictNewerWins by default.
+		Conflict ConflictPolicy
+		// ConflictSuffix is inserted before the extension of the local file
+		// renamed by ConflictKeepBoth, ".conflict" by default.
+		ConflictSuffix string
+	}
+
+	// SyncReport lists the paths, relative to the synced folders, changed by
+	// SyncFolder.
+	SyncReport struct {
+		Uploaded      []string
+		Downloaded    []string
+		DeletedLocal  []string
+		DeletedRemote []string
+		// Conflicts are the files changed on both sides, they are resolved
+		// according to the conflict policy and also listed with the changes
+		// made.
+		Conflicts []string
+		// Errors holds the error of each file which failed to sync. These
+		// files are synced again by the next run.
+		Errors map[string]error
+	}
+
+	// syncState is the state of the files after the last sync, keyed by
+	// lowercase relative path.
+	syncState struct {
+		RemotePath string                     `json:"remotePath"`
+		Files      map[string]*syncStateEntry `json:"files"`
+	}
+
+	syncStateEntry struct {
+		Path    string    `json:"path"`
+		MD5     string    `json:"md5"`
+		Size    int64     `json:"size"`
+		ModTime time.Time `json:"modTime"`
+		NodeId  string    `json:"nodeId"`
+	}
+
+	localSyncFile struct {
+		rel     string
+		md5     string
+		size    int64
+		modTime time.Time
+	}
+
+	remoteSyncFile struct {
+		rel  string
+		node *node.Node
+	}
+
+	folderSync struct {
+		c          *Client
+		ctx        context.Context
+		localPath  string
+		remotePath string
+		opts       SyncFolderOptions
+		base       *syncState
+		state      *syncState
+		local      map[string]*localSyncFile
+		remote     map[string]*remoteSyncFile
+		report     *SyncReport
+	}
+)
+
+// SyncFolder synchronizes the files of localPath and remotePath in both
+// directions. The additions, edits and deletions made on each side since the
+// last sync, which state is persisted in the state file, are applied to the
+// other side. Files changed on both sides are resolved with the conflict
+// policy. Remote deletions move the nodes to the trash. A nil opts uses the
+// defaults.
+//
+// The errors of the individual files are collected in the report, the
+// returned error is only set if the sync could not run.
+func (c *Client) SyncFolder(localPath, remotePath string, opts *SyncFolderOptions) (*SyncReport, error) {
+	return c.SyncFolderContext(context.Background(), localPath, remotePath, opts)
+}
+
+// SyncFolderContext is like SyncFolder but uses ctx for all the requests.
+func (c *Client) SyncFolderContext(ctx context.Context, localPath, remotePath string, opts *SyncFolderOptions) (*SyncReport, error) {
+	log.Debugf("syncing %q with %q", localPath, remotePath)
+
+	s := &folderSync{
+		c:          c,
+		ctx:        ctx,
+		localPath:  filepath.Clean(localPath),
+		remotePath: path.Clean("/" + remotePath),
+		report:     &SyncReport{Errors: map[string]error{}},
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.StateFile == "" {
+		s.opts.StateFile = filepath.Join(s.localPath, defaultSyncStateFile)
+	}
+	s.opts.StateFile = filepath.Clean(s.opts.StateFile)
+	if s.opts.Conflict == "" {
+		s.opts.Conflict = ConflictNewerWins
+	}
+	if s.opts.ConflictSuffix == "" {
+		s.opts.ConflictSuffix = ".conflict"
+	}
+	switch s.opts.Conflict {
+	case ConflictNewerWins, ConflictKeepBoth, ConflictPreferLocal, ConflictPreferRemote:
+	default:
+		log.Errorf("%s: %q", constants.ErrUnknownConflictPolicy, s.opts.Conflict)
+		return nil, constants.ErrUnknownConflictPolicy
+	}
+
+	if err := os.MkdirAll(s.localPath, os.FileMode(0755)); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFolder, err)
+		return nil, constants.ErrCreateFolder
+	}
+	if err := c.GetNodeTree().SyncContext(ctx); err != nil {
+		return nil, err
+	}
+	s.base = readSyncState(s.opts.StateFile, s.remotePath)
+	s.state = &syncState{RemotePath: s.remotePath, Files: map[string]*syncStateEntry{}}
+	if err := s.scanLocal(); err != nil {
+		return nil, err
+	}
+	if err := s.scanRemote(); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for key := range s.local {
+		keys[key] = true
+	}
+	for key := range s.remote {
+		keys[key] = true
+	}
+	for key := range s.base.Files {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		if err := ctx.Err(); err != nil {
+			// keep the state of the files not synced yet.
+			if b, ok := s.base.Files[key]; ok {
+				s.state.Files[key] = b
+			}
+			continue
+		}
+		s.reconcile(key)
+	}
+
+	if err := writeSyncState(s.opts.StateFile, s.state); err != nil {
+		return s.report, err
+	}
+	return s.report, ctx.Err()
+}
+
+// scanLocal lists the files of the local folder. The md5 of a file which size
+// and modification time are unchanged since the last sync is not computed
+// again.
+func (s *folderSync) scanLocal() error {
+	s.local = map[string]*localSyncFile{}
+	return filepath.WalkDir(s.localPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrStatFile, err)
+			return constants.ErrStatFile
+		}
+		if !d.Type().IsRegular() || p == s.opts.StateFile || p == s.opts.StateFile+".tmp" ||
+			strings.HasSuffix(p, partialSuffix) || strings.HasSuffix(p, partialStateSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrStatFile, err)
+			return constants.ErrStatFile
+		}
+		rel, _ := filepath.Rel(s.localPath, p)
+		rel = filepath.ToSlash(rel)
+		f := &localSyncFile{rel: rel, size: info.Size(), modTime: info.ModTime()}
+		if b, ok := s.base.Files[strings.ToLower(rel)]; ok && b.Size == f.size && b.ModTime.Equal(f.modTime) {
+			f.md5 = b.MD5
+		} else if f.md5, err = fileMD5(p); err != nil {
+			return err
+		}
+		s.local[strings.ToLower(rel)] = f
+		return nil
+	})
+}
+
+// scanRemote lists the files of the remote folder, creating it if needed.
+func (s *folderSync) scanRemote() error {
+	s.remote = map[string]*remoteSyncFile{}
+	if _, err := s.c.GetNodeTree().MkDirAllContext(s.ctx, s.remotePath); err != nil {
+		return err
+	}
+	root := strings.Trim(s.remotePath, "/")
+	if root == "" {
+		root = "."
+	}
+	return fs.WalkDir(s.c.GetNodeTree().FSContext(s.ctx), root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel := p
+		if root != "." {
+			rel = strings.TrimPrefix(p, root+"/")
+		}
+		s.remote[strings.ToLower(rel)] = &remoteSyncFile{rel: rel, node: info.Sys().(*node.Node)}
+		return nil
+	})
+}
+
+// reconcile compares both sides of a file with its state after the last sync
+// and applies the changes.
+func (s *folderSync) reconcile(key string) {
+	l, r, b := s.local[key], s.remote[key], s.base.Files[key]
+	localChanged := (l == nil) != (b == nil) || (l != nil && b != nil && l.md5 != b.MD5)
+	// an empty file is not uploaded, its state has no node.
+	remoteChanged := (r == nil) != (b == nil || b.NodeId == "") || (r != nil && b != nil && r.node.ContentProperties.MD5 != b.MD5)
+
+	var err error
+	switch {
+	case l == nil && r == nil:
+		// deleted on both sides.
+	case l != nil && r != nil && l.md5 == r.node.ContentProperties.MD5:
+		s.keep(key, l, r.node)
+	case !localChanged && !remoteChanged:
+		var n *node.Node
+		if r != nil {
+			n = r.node
+		}
+		s.keep(key, l, n)
+	case localChanged && !remoteChanged:
+		err = s.pushLocal(key, l, r)
+	case !localChanged && remoteChanged:
+		err = s.pullRemote(key, l, r)
+	default:
+		s.report.Conflicts = append(s.report.Conflicts, syncRel(l, r))
+		err = s.resolve(key, l, r)
+	}
+	if err != nil {
+		log.Errorf("syncing %s: %s", syncRel(l, r), err)
+		s.report.Errors[syncRel(l, r)] = err
+		if b != nil {
+			s.state.Files[key] = b
+		}
+	}
+}
+
+// resolve applies the conflict policy to a file changed on both sides.
+func (s *folderSync) resolve(key string, l *localSyncFile, r *remoteSyncFile) error {
+	switch s.opts.Conflict {
+	case ConflictPreferLocal:
+		return s.pushLocal(key, l, r)
+	case ConflictPreferRemote:
+		return s.pullRemote(key, l, r)
+	}
+
+	// an edit wins against a deletion.
+	if l == nil {
+		return s.pullRemote(key, l, r)
+	}
+	if r == nil {
+		return s.pushLocal(key, l, r)
+	}
+	if s.opts.Conflict == ConflictNewerWins {
+		if l.modTime.After(r.node.ModTime()) {
+			return s.pushLocal(key, l, r)
+		}
+		return s.pullRemote(key, l, r)
+	}
+
+	// keep both: upload the local version under a new name.
+	renamed := s.conflictName(l.rel)
+	if err := os.Rename(s.localFile(l.rel), s.localFile(renamed)); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	renamedFile := *l
+	renamedFile.rel = renamed
+	if err := s.pushLocal(strings.ToLower(renamed), &renamedFile, nil); err != nil {
+		return err
+	}
+	return s.pullRemote(key, nil, r)
+}
+
+// pushLocal applies the local side to the remote: uploads the local file or
+// trashes the remote one.
+func (s *folderSync) pushLocal(key string, l *localSyncFile, r *remoteSyncFile) error {
+	if l == nil {
+		if err := s.c.GetNodeTree().RemoveNodeContext(s.ctx, r.node); err != nil {
+			return err
+		}
+		s.report.DeletedRemote = append(s.report.DeletedRemote, r.rel)
+		return nil
+	}
+
+	f, err := os.Open(s.localFile(l.rel))
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrOpenFile, err)
+		return constants.ErrOpenFile
+	}
+	defer f.Close()
+	n, err := s.c.UploadContext(s.ctx, path.Join(s.remotePath, l.rel), true, nil, node.NewProperty(), f)
+	// an empty file cannot be uploaded, a new one is synced without a node.
+	if err == constants.ErrNoContentsToUpload && r == nil {
+		log.Debugf("%q is empty, skipping the upload", l.rel)
+		s.keep(key, l, nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.report.Uploaded = append(s.report.Uploaded, l.rel)
+	s.keep(key, l, n)
+	return nil
+}
+
+// pullRemote applies the remote side to the local: downloads the remote file
+// or deletes the local one.
+func (s *folderSync) pullRemote(key string, l *localSyncFile, r *remoteSyncFile) error {
+	if r == nil {
+		if err := os.Remove(s.localFile(l.rel)); err != nil && !os.IsNotExist(err) {
+			log.Errorf("%s: %s", constants.ErrOpenFile, err)
+			return constants.ErrOpenFile
+		}
+		s.report.DeletedLocal = append(s.report.DeletedLocal, l.rel)
+		return nil
+	}
+
+	rel := r.rel
+	if l != nil {
+		rel = l.rel
+	}
+	localFile := s.localFile(rel)
+	if err := os.MkdirAll(filepath.Dir(localFile), os.FileMode(0755)); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFolder, err)
+		return constants.ErrCreateFolder
+	}
+	if err := s.c.resumeDownload(s.ctx, r.node, localFile); err != nil {
+		return err
+	}
+	modTime := r.node.ModTime()
+	os.Chtimes(localFile, modTime, modTime)
+	info, err := os.Stat(localFile)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrStatFile, err)
+		return constants.ErrStatFile
+	}
+	s.report.Downloaded = append(s.report.Downloaded, rel)
+	s.keep(key, &localSyncFile{rel: rel, md5: r.node.ContentProperties.MD5, size: info.Size(), modTime: info.ModTime()}, r.node)
+	return nil
+}
+
+// keep records the file as synced with the node n, nil for an empty file
+// which has not been uploaded.
+func (s *folderSync) keep(key string, l *localSyncFile, n *node.Node) {
+	entry := &syncStateEntry{
+		Path:    l.rel,
+		MD5:     l.md5,
+		Size:    l.size,
+		ModTime: l.modTime,
+	}
+	if n != nil {
+		entry.NodeId = n.Id
+	}
+	s.state.Files[key] = entry
+}
+
+func (s *folderSync) localFile(rel string) string {
+	return filepath.Join(s.localPath, filepath.FromSlash(rel))
+}
+
+// conflictName returns a free name for the local copy of a conflicting file,
+// inserting the conflict suffix before the extension.
+func (s *folderSync) conflictName(rel string) string {
+	ext := path.Ext(rel)
+	base := strings.TrimSuffix(rel, ext) + s.opts.ConflictSuffix
+	name := base + ext
+	for i := 2; ; i++ {
+		_, inLocal := s.local[strings.ToLower(name)]
+		_, inRemote := s.remote[strings.ToLower(name)]
+		if _, err := os.Lstat(s.localFile(name)); os.IsNotExist(err) && !inLocal && !inRemote {
+			return name
+		}
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+func syncRel(l *localSyncFile, r *remoteSyncFile) string {
+	if l != nil {
+		return l.rel
+	}
+	return r.rel
+}
+
+// readSyncState returns the state saved at stateFile for the remote path, or
+// an empty state if there is none.
+func readSyncState(stateFile, remotePath string) *syncState {
+	state := &syncState{}
+	content, err := os.ReadFile(stateFile)
+	if err == nil {
+		err = json.Unmarshal(content, state)
+	}
+	if err != nil || state.RemotePath != remotePath || state.Files == nil {
+		if err != nil && !os.IsNotExist(err) {
+			log.Errorf("%s: %s", constants.ErrJSONDecoding, err)
+		}
+		return &syncState{RemotePath: remotePath, Files: map[string]*syncStateEntry{}}
+	}
+	return state
+}
+
+// writeSyncState saves the state to a temporary file renamed to stateFile.
+func writeSyncState(stateFile string, state *syncState) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONEncoding, err)
+		return constants.ErrJSONEncoding
+	}
+	tmp := stateFile + ".tmp"
+	if err := os.WriteFile(tmp, content, os.FileMode(0644)); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	if err := os.Rename(tmp, stateFile); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	return nil
+}
+
+// fileMD5 returns the md5 of the content of the file in hex.
+func fileMD5(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrOpenFile, err)
+		return "", constants.ErrOpenFile
+	}
+	defer f.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		log.Errorf("%s: %s", constants.ErrOpenFile, err)
+		return "", constants.ErrOpenFile
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyncFolder(t *testing.T) {
+	server := newTestServer(t)
+	c := newTestClient(t, server, "")
+	local := t.TempDir()
+	writeLocal := func(name, content string, modTime time.Time) {
+		t.Helper()
+		p := filepath.Join(local, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(p, modTime, modTime)
+	}
+	readLocal := func(name string) string {
+		content, _ := os.ReadFile(filepath.Join(local, filepath.FromSlash(name)))
+		return string(content)
+	}
+	readRemote := func(name string) string {
+		content, _ := server.ReadFile("/sync/" + name)
+		return string(content)
+	}
+	sync := func(policy ConflictPolicy) *SyncReport {
+		t.Helper()
+		report, err := c.SyncFolder(local, "/sync", &SyncFolderOptions{Conflict: policy})
+		if err != nil {
+			t.Fatalf("c.SyncFolder() error: %s", err)
+		}
+		if len(report.Errors) > 0 {
+			t.Fatalf("c.SyncFolder() errors: %v", report.Errors)
+		}
+		return report
+	}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	writeLocal("local.txt", "local", past)
+	writeLocal("sub/both.txt", "both", past)
+	server.PutFile("/sync/remote.txt", []byte("remote"))
+	server.PutFile("/sync/sub/both.txt", []byte("both"))
+	report := sync("")
+	if want := []string{"local.txt"}; !reflect.DeepEqual(want, report.Uploaded) {
+		t.Errorf("first sync uploaded: want %q got %q", want, report.Uploaded)
+	}
+	if want := []string{"remote.txt"}; !reflect.DeepEqual(want, report.Downloaded) {
+		t.Errorf("first sync downloaded: want %q got %q", want, report.Downloaded)
+	}
+	if readRemote("local.txt") != "local" || readLocal("remote.txt") != "remote" {
+		t.Errorf("first sync: the files were not copied")
+	}
+
+	if report := sync(""); !reflect.DeepEqual(&SyncReport{Errors: report.Errors}, report) {
+		t.Errorf("sync without changes: want an empty report got %+v", report)
+	}
+
+	// edits and deletions on both sides.
+	writeLocal("local.txt", "local edit", past)
+	server.PutFile("/sync/sub/both.txt", []byte("remote edit"))
+	os.Remove(filepath.Join(local, "remote.txt"))
+	report = sync("")
+	if want := []string{"local.txt"}; !reflect.DeepEqual(want, report.Uploaded) {
+		t.Errorf("edit sync uploaded: want %q got %q", want, report.Uploaded)
+	}
+	if want := []string{"sub/both.txt"}; !reflect.DeepEqual(want, report.Downloaded) {
+		t.Errorf("edit sync downloaded: want %q got %q", want, report.Downloaded)
+	}
+	if want := []string{"remote.txt"}; !reflect.DeepEqual(want, report.DeletedRemote) {
+		t.Errorf("edit sync deleted remote: want %q got %q", want, report.DeletedRemote)
+	}
+	if _, ok := server.Lookup("/sync/remote.txt"); ok {
+		t.Errorf("edit sync: remote.txt was not trashed")
+	}
+	if readLocal("sub/both.txt") != "remote edit" || readRemote("local.txt") != "local edit" {
+		t.Errorf("edit sync: the edits were not copied")
+	}
+
+	// conflicts.
+	writeLocal("local.txt", "newer local", future)
+	server.PutFile("/sync/local.txt", []byte("older remote"))
+	writeLocal("sub/both.txt", "local version", past)
+	server.PutFile("/sync/sub/both.txt", []byte("remote version"))
+	report = sync(ConflictNewerWins)
+	if want := []string{"local.txt", "sub/both.txt"}; !reflect.DeepEqual(want, report.Conflicts) {
+		t.Errorf("newer wins conflicts: want %q got %q", want, report.Conflicts)
+	}
+	if readRemote("local.txt") != "newer local" || readLocal("sub/both.txt") != "remote version" {
+		t.Errorf("newer wins: the newer versions were not kept")
+	}
+
+	writeLocal("sub/both.txt", "local again", future)
+	server.PutFile("/sync/sub/both.txt", []byte("remote again"))
+	report = sync(ConflictKeepBoth)
+	if want := []string{"sub/both.conflict.txt"}; !reflect.DeepEqual(want, report.Uploaded) {
+		t.Errorf("keep both uploaded: want %q got %q", want, report.Uploaded)
+	}
+	if readLocal("sub/both.txt") != "remote again" || readRemote("sub/both.conflict.txt") != "local again" || readLocal("sub/both.conflict.txt") != "local again" {
+		t.Errorf("keep both: both versions were not kept")
+	}
+
+	// an edit wins against a deletion.
+	writeLocal("local.txt", "edited", future)
+	server.Trash("/sync/local.txt")
+	report = sync(ConflictNewerWins)
+	if readRemote("local.txt") != "edited" {
+		t.Errorf("edit against deletion: want the edit uploaded got %+v", report)
+	}
+	if err := c.GetNodeTree().Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if report := sync(""); len(report.Uploaded)+len(report.Downloaded)+len(report.Conflicts) > 0 {
+		t.Errorf("sync after edit against deletion: want an empty report got %+v", report)
+	}
+
+	// an empty file cannot be uploaded, it is synced all the same.
+	writeLocal("empty.txt", "", past)
+	sync("")
+	if report := sync(""); !reflect.DeepEqual(&SyncReport{Errors: report.Errors}, report) {
+		t.Errorf("sync with an empty file: want an empty report got %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(local, "empty.txt")); err != nil {
+		t.Errorf("sync with an empty file: the file was deleted: %s", err)
+	}
+	server.PutFile("/sync/empty.txt", []byte("remote content"))
+	report = sync("")
+	if want := []string{"empty.txt"}; !reflect.DeepEqual(want, report.Downloaded) || len(report.Conflicts) > 0 {
+		t.Errorf("remote edit of an empty file: want %q downloaded got %+v", want, report)
+	}
+	if readLocal("empty.txt") != "remote content" {
+		t.Errorf("remote edit of an empty file: the edit was not copied")
+	}
+}
@@ -2,23 +2,20 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/montaguethomas/acd-go/constants"
 	"github.com/montaguethomas/acd-go/log"
 	"github.com/montaguethomas/acd-go/node"
 )
 
-// apiGetTrashResponse is the response body for listing trash
-type apiGetTrashResponse struct {
-	Count     uint64       `json:"count,omitempty"`
-	NextToken string       `json:"nextToken,omitempty"`
-	Nodes     []*node.Node `json:"data,omitempty"`
-}
-
 type apiBulkPurgeRequest struct {
 	Recurse string   `json:"recurse"`
 	NodeIds []string `json:"nodeIds"` // Member must have length less than or equal to 50
@@ -28,56 +25,49 @@ type apiBulkPurgeResponse struct {
 	ErrorMap map[string]int `json:"errorMap"`
 }
 
+type apiBulkRestoreRequest struct {
+	NodeIds []string `json:"nodeIds"` // Member must have length less than or equal to 50
+}
+
+type apiBulkRestoreResponse struct {
+	ErrorMap map[string]int `json:"errorMap"`
+}
+
+// NodeErrors is returned by the bulk operations when some of the nodes have
+// failed. It maps the Id of each failed node to its error.
+type NodeErrors map[string]error
+
+func (e NodeErrors) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	failures := make([]string, 0, len(ids))
+	for _, id := range ids {
+		failures = append(failures, fmt.Sprintf("%s: %s", id, e[id]))
+	}
+	return fmt.Sprintf("%d nodes failed: %s", len(e), strings.Join(failures, ", "))
+}
+
 // GetTrash will get all the nodes in the trash
 func (c *Client) GetTrash() ([]*node.Node, error) {
+	return c.GetTrashContext(context.Background())
+}
+
+// GetTrashContext is like GetTrash but uses ctx for the requests.
+func (c *Client) GetTrashContext(ctx context.Context) ([]*node.Node, error) {
 	log.Debug("client.GetTrash starting.")
 	defer log.Debug("client.GetTrash completed.")
 
 	// Get nodes in the trash
-	var nextToken string
 	var nodes []*node.Node
-	for {
-		urlStr := c.GetMetadataURL("trash")
-		u, err := url.Parse(urlStr)
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrParsingURL, urlStr)
-			return nil, constants.ErrParsingURL
-		}
-
-		v := url.Values{}
-		v.Set("limit", "200")
-		if nextToken != "" {
-			v.Set("startToken", nextToken)
-		}
-		u.RawQuery = v.Encode()
-
-		// Make Request
-		req, err := http.NewRequest("GET", u.String(), nil)
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
-			return nil, constants.ErrCreatingHTTPRequest
-		}
-		req.Header.Set("Content-Type", "application/json")
-		res, err := c.Do(req)
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-			return nil, constants.ErrDoingHTTPRequest
-		}
-
-		// Handle Response
-		defer res.Body.Close()
-		response := apiGetTrashResponse{}
-		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
-			log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
-			return nil, constants.ErrJSONDecodingResponseBody
-		}
-
-		nextToken = response.NextToken
-		nodes = append(nodes, response.Nodes...)
-
-		if nextToken == "" {
-			break
-		}
+	err := node.ListNodes(ctx, c, "trash", url.Values{}, func(page []*node.Node) error {
+		nodes = append(nodes, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return nodes, nil
 }
@@ -104,6 +94,11 @@ func slicesChunk[T any](slice []T, n uint64) <-chan []T {
 
 // PurgeNodes will purge the provided nodes
 func (c *Client) PurgeNodes(nodes []*node.Node) error {
+	return c.PurgeNodesContext(context.Background(), nodes)
+}
+
+// PurgeNodesContext is like PurgeNodes but uses ctx for the requests.
+func (c *Client) PurgeNodesContext(ctx context.Context, nodes []*node.Node) error {
 	log.Debug("client.PurgeNodes starting.")
 	defer log.Debug("client.PurgeNodes completed.")
 
@@ -127,7 +122,7 @@ func (c *Client) PurgeNodes(nodes []*node.Node) error {
 		}
 
 		// Build Request
-		req, err := http.NewRequest("POST", c.GetMetadataURL("bulk/nodes/purge"), bytes.NewBuffer(requestJsonBytes))
+		req, err := http.NewRequestWithContext(ctx, "POST", c.GetMetadataURL("bulk/nodes/purge"), bytes.NewBuffer(requestJsonBytes))
 		if err != nil {
 			log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 			return constants.ErrCreatingHTTPRequest
@@ -138,7 +133,7 @@ func (c *Client) PurgeNodes(nodes []*node.Node) error {
 		res, err := c.Do(req)
 		if err != nil {
 			log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-			return constants.ErrDoingHTTPRequest
+			return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
 		}
 		if err := c.CheckResponse(res); err != nil {
 			return err
@@ -165,14 +160,190 @@ func (c *Client) PurgeNodes(nodes []*node.Node) error {
 	return nil
 }
 
-// PurgeTrash will purge all nodes in the trash
+// PurgeTrash will purge the nodes in the trash selected by the
+// PurgeTrashPolicy of the Config, or all of them if it is not set. A trashed
+// folder is purged with its descendants, which are listed to check them
+// against the policy.
 func (c *Client) PurgeTrash() error {
+	return c.PurgeTrashContext(context.Background())
+}
+
+// PurgeTrashContext is like PurgeTrash but uses ctx for the requests.
+func (c *Client) PurgeTrashContext(ctx context.Context) error {
 	log.Debug("client.PurgeTrash starting.")
 	defer log.Debug("client.PurgeTrash completed.")
 
-	nodes, err := c.GetTrash()
+	nodes, err := c.PurgeTrashDryRunContext(ctx)
 	if err != nil {
 		return err
 	}
-	return c.PurgeNodes(nodes)
+	return c.PurgeNodesContext(ctx, nodes)
+}
+
+// PurgeTrashDryRun returns the nodes of the trash PurgeTrash would purge,
+// without purging them.
+func (c *Client) PurgeTrashDryRun() ([]*node.Node, error) {
+	return c.PurgeTrashDryRunContext(context.Background())
+}
+
+// PurgeTrashDryRunContext is like PurgeTrashDryRun but uses ctx for the
+// requests.
+func (c *Client) PurgeTrashDryRunContext(ctx context.Context) ([]*node.Node, error) {
+	nodes, err := c.GetTrashContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.config.mutex.RLock()
+	policy := c.config.PurgeTrashPolicy
+	c.config.mutex.RUnlock()
+	if policy == nil {
+		return nodes, nil
+	}
+	index := c.newTrashIndex(ctx, nodes)
+	if err := c.listTrashDescendants(ctx, index, nodes); err != nil {
+		return nil, err
+	}
+	selected, err := policy.selectNodes(nodes, time.Now(), index.originalPath, index.descendants)
+	if err != nil {
+		return nil, err
+	}
+	if index.err != nil {
+		return nil, index.err
+	}
+	return selected, nil
+}
+
+// RestoreNode restores the node from the trash and adds it back to the tree
+// under its original parents. It returns the node of the tree.
+func (c *Client) RestoreNode(n *node.Node) (*node.Node, error) {
+	return c.RestoreNodeContext(context.Background(), n)
+}
+
+// RestoreNodeContext is like RestoreNode but uses ctx for the request.
+func (c *Client) RestoreNodeContext(ctx context.Context, n *node.Node) (*node.Node, error) {
+	log.Debug("client.RestoreNode starting.")
+	defer log.Debug("client.RestoreNode completed.")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.GetMetadataURL(fmt.Sprintf("trash/%s/restore", n.Id)), nil)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return nil, constants.ErrCreatingHTTPRequest
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	var restored *node.Node
+	if err := json.NewDecoder(res.Body).Decode(&restored); err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
+		return nil, constants.ErrJSONDecodingResponseBody
+	}
+
+	return c.GetNodeTree().AddNode(restored)
+}
+
+// RestoreNodes restores the nodes from the trash, 50 at a time, and adds them
+// back to the tree under their original parents. It returns the restored nodes
+// of the tree and, if some nodes have failed, a NodeErrors with their errors.
+func (c *Client) RestoreNodes(nodes []*node.Node) ([]*node.Node, error) {
+	return c.RestoreNodesContext(context.Background(), nodes)
+}
+
+// RestoreNodesContext is like RestoreNodes but uses ctx for the requests.
+func (c *Client) RestoreNodesContext(ctx context.Context, nodes []*node.Node) ([]*node.Node, error) {
+	log.Debug("client.RestoreNodes starting.")
+	defer log.Debug("client.RestoreNodes completed.")
+
+	var available []*node.Node
+	var err error
+	failures := NodeErrors{}
+	for i := 0; i < len(nodes); i += 50 {
+		chunk := nodes[i:min(i+50, len(nodes))]
+		var errorMap map[string]int
+		if errorMap, err = c.restoreNodes(ctx, chunk); err != nil {
+			break
+		}
+		for _, n := range chunk {
+			if status, ok := errorMap[n.Id]; ok {
+				log.Errorf("restoring node %s: %s", n.Id, constants.ErrorForStatus(status))
+				failures[n.Id] = constants.ErrorForStatus(status)
+				continue
+			}
+			// the nodes of the trash are stale, the restored nodes are
+			// requested again.
+			restored, getErr := c.getNode(ctx, n.Id)
+			if getErr != nil {
+				failures[n.Id] = getErr
+				continue
+			}
+			available = append(available, restored)
+		}
+	}
+
+	// the restored nodes are added at once, the tree being scanned once for
+	// the children of the restored folders.
+	restored, addErr := c.GetNodeTree().AddNodes(available)
+	if addErr != nil {
+		added := make(map[string]bool, len(restored))
+		for _, n := range restored {
+			added[n.Id] = true
+		}
+		for _, n := range available {
+			if !added[n.Id] {
+				failures[n.Id] = addErr
+			}
+		}
+	}
+
+	if err != nil {
+		return restored, err
+	}
+	if len(failures) > 0 {
+		return restored, failures
+	}
+	return restored, nil
+}
+
+// restoreNodes restores a chunk of up to 50 nodes and returns the error
+// status of the nodes which have failed by Id.
+func (c *Client) restoreNodes(ctx context.Context, chunk []*node.Node) (map[string]int, error) {
+	nodeIds := make([]string, 0, len(chunk))
+	for _, n := range chunk {
+		nodeIds = append(nodeIds, n.Id)
+	}
+	requestJsonBytes, err := json.Marshal(&apiBulkRestoreRequest{NodeIds: nodeIds})
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONEncoding, err)
+		return nil, constants.ErrJSONEncoding
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.GetMetadataURL("bulk/nodes/restore"), bytes.NewBuffer(requestJsonBytes))
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return nil, constants.ErrCreatingHTTPRequest
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.Do(req)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	response := apiBulkRestoreResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
+		return nil, constants.ErrJSONDecodingResponseBody
+	}
+	return response.ErrorMap, nil
 }
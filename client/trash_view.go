@@ -0,0 +1,326 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+	"github.com/montaguethomas/acd-go/node"
+)
+
+type (
+	// TrashView is the content of the trash, as returned by GetTrashView.
+	TrashView struct {
+		// Items are the trashed nodes which are not under another item,
+		// sorted by original path.
+		Items []*TrashItem
+		// Count is the number of items, including the nested ones.
+		Count int
+		// Size is the total size of the items in bytes.
+		Size uint64
+	}
+
+	// TrashItem is a node of the trash.
+	TrashItem struct {
+		Node *node.Node
+		// OriginalPath is the path of the node before it was trashed. It is
+		// empty if one of its parents cannot be found.
+		OriginalPath string
+		// TrashedDate is when the node was trashed, its ModifiedDate, or the
+		// one of its trashed ancestor for a node trashed with its folder.
+		TrashedDate time.Time
+		// Children are the items which were under this folder when they were
+		// trashed, sorted by original path.
+		Children []*TrashItem
+		// Size is the size of the node and of its children in bytes.
+		Size uint64
+	}
+
+	// TrashFilter selects the items of a TrashView. The zero value selects
+	// every item.
+	TrashFilter struct {
+		// PathPrefix selects the items whose original path is, or is under,
+		// the path. The comparison is case insensitive.
+		PathPrefix string
+		// TrashedAfter selects the items trashed at or after the date.
+		TrashedAfter time.Time
+		// TrashedBefore selects the items trashed before the date.
+		TrashedBefore time.Time
+	}
+)
+
+// GetTrashView returns the nodes of the trash selected by filter, with their
+// original paths, the trashed folders grouping their descendants. The
+// descendants of the trashed folders, which GetTrash does not list, are listed
+// too. A nil filter selects every node.
+func (c *Client) GetTrashView(filter *TrashFilter) (*TrashView, error) {
+	return c.GetTrashViewContext(context.Background(), filter)
+}
+
+// GetTrashViewContext is like GetTrashView but uses ctx for the requests.
+func (c *Client) GetTrashViewContext(ctx context.Context, filter *TrashFilter) (*TrashView, error) {
+	trash, err := c.GetTrashContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		filter = &TrashFilter{}
+	}
+
+	index := c.newTrashIndex(ctx, trash)
+	if err := c.listTrashDescendants(ctx, index, trash); err != nil {
+		return nil, err
+	}
+	inTrash := make(map[string]bool, len(trash))
+	for _, n := range trash {
+		inTrash[n.Id] = true
+	}
+	items := make(map[string]*TrashItem, len(index.byId))
+	for _, n := range index.byId {
+		item := &TrashItem{
+			Node:         n,
+			OriginalPath: index.originalPath(n),
+			TrashedDate:  n.ModifiedDate,
+		}
+		if !inTrash[n.Id] {
+			item.TrashedDate = index.trashedDate(n, inTrash)
+		}
+		if filter.match(item) {
+			items[n.Id] = item
+		}
+	}
+	if index.err != nil {
+		return nil, index.err
+	}
+
+	view := &TrashView{}
+	for _, item := range items {
+		view.Count++
+		view.Size += item.Node.ContentProperties.Size
+		if ancestor := index.trashedAncestor(item.Node, items); ancestor != nil {
+			ancestor.Children = append(ancestor.Children, item)
+			continue
+		}
+		view.Items = append(view.Items, item)
+	}
+	sortTrashItems(view.Items)
+	return view, nil
+}
+
+// sortTrashItems sorts the items and their children by original path and
+// computes their size.
+func sortTrashItems(items []*TrashItem) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].OriginalPath != items[j].OriginalPath {
+			return items[i].OriginalPath < items[j].OriginalPath
+		}
+		return items[i].Node.Id < items[j].Node.Id
+	})
+	for _, item := range items {
+		sortTrashItems(item.Children)
+		item.Size = item.Node.ContentProperties.Size
+		for _, child := range item.Children {
+			item.Size += child.Size
+		}
+	}
+}
+
+func (f *TrashFilter) match(item *TrashItem) bool {
+	if !f.TrashedAfter.IsZero() && item.TrashedDate.Before(f.TrashedAfter) {
+		return false
+	}
+	if !f.TrashedBefore.IsZero() && !item.TrashedDate.Before(f.TrashedBefore) {
+		return false
+	}
+	if f.PathPrefix == "" {
+		return true
+	}
+	prefix := strings.ToLower(path.Clean("/" + f.PathPrefix))
+	p := strings.ToLower(item.OriginalPath)
+	return p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// trashIndex looks up the parents of the nodes of the trash, which are either
+// in the trash, in the tree or requested by Id, as a lazy tree may not have
+// loaded them, and the descendants of the trashed folders once listed.
+type trashIndex struct {
+	ctx      context.Context
+	client   *Client
+	tree     *node.Tree
+	byId     map[string]*node.Node
+	children map[string][]*node.Node
+	// fetched are the parents requested by Id, nil for the ones not found.
+	fetched map[string]*node.Node
+	// err is the first error requesting a parent.
+	err error
+}
+
+func (c *Client) newTrashIndex(ctx context.Context, trash []*node.Node) *trashIndex {
+	index := &trashIndex{
+		ctx:      ctx,
+		client:   c,
+		tree:     c.GetNodeTree(),
+		byId:     make(map[string]*node.Node, len(trash)),
+		children: make(map[string][]*node.Node),
+		fetched:  make(map[string]*node.Node),
+	}
+	for _, n := range trash {
+		index.add(n)
+	}
+	return index
+}
+
+func (ti *trashIndex) add(n *node.Node) {
+	ti.byId[n.Id] = n
+	for _, parentId := range n.Parents {
+		ti.children[parentId] = append(ti.children[parentId], n)
+	}
+}
+
+// listTrashDescendants lists the children of the trashed folders recursively
+// and adds them to the index. Trashing a folder only moves the folder itself
+// to the trash, GetTrash does not list its descendants.
+func (c *Client) listTrashDescendants(ctx context.Context, index *trashIndex, trash []*node.Node) error {
+	var folders []*node.Node
+	for _, n := range trash {
+		if n.IsDir() {
+			folders = append(folders, n)
+		}
+	}
+	listed := make(map[string]bool)
+	for len(folders) > 0 {
+		folder := folders[len(folders)-1]
+		folders = folders[:len(folders)-1]
+		if listed[folder.Id] {
+			continue
+		}
+		listed[folder.Id] = true
+		err := node.ListNodes(ctx, c, fmt.Sprintf("nodes/%s/children", folder.Id), url.Values{}, func(page []*node.Node) error {
+			for _, child := range page {
+				if _, ok := index.byId[child.Id]; !ok {
+					index.add(child)
+				}
+				if child.IsDir() {
+					folders = append(folders, child)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// descendants returns the nodes of the index under the node.
+func (ti *trashIndex) descendants(n *node.Node) []*node.Node {
+	var descendants []*node.Node
+	seen := map[string]bool{n.Id: true}
+	for queue := []string{n.Id}; len(queue) > 0; queue = queue[1:] {
+		for _, child := range ti.children[queue[0]] {
+			if seen[child.Id] {
+				continue
+			}
+			seen[child.Id] = true
+			descendants = append(descendants, child)
+			queue = append(queue, child.Id)
+		}
+	}
+	return descendants
+}
+
+// parent returns the first parent of the node, or nil if it cannot be found.
+func (ti *trashIndex) parent(n *node.Node) *node.Node {
+	if len(n.Parents) == 0 {
+		return nil
+	}
+	if parent, ok := ti.byId[n.Parents[0]]; ok {
+		return parent
+	}
+	if parent, err := ti.tree.LookupById(n.Parents[0]); err == nil {
+		return parent
+	}
+	if parent, ok := ti.fetched[n.Parents[0]]; ok {
+		return parent
+	}
+	parent, err := ti.client.getNode(ti.ctx, n.Parents[0])
+	if err != nil && !errors.Is(err, constants.ErrResponseNotFound) && ti.err == nil {
+		ti.err = err
+	}
+	ti.fetched[n.Parents[0]] = parent
+	return parent
+}
+
+// getNode requests the node identified by id.
+func (c *Client) getNode(ctx context.Context, id string) (*node.Node, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.GetMetadataURL(fmt.Sprintf("nodes/%s", id)), nil)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return nil, constants.ErrCreatingHTTPRequest
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := c.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	var n *node.Node
+	if err := json.NewDecoder(res.Body).Decode(&n); err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
+		return nil, constants.ErrJSONDecodingResponseBody
+	}
+	return n, nil
+}
+
+// originalPath returns the path of the node before it was trashed, or an
+// empty string if one of its parents cannot be found.
+func (ti *trashIndex) originalPath(n *node.Node) string {
+	if n.IsRoot {
+		return "/"
+	}
+	parent := ti.parent(n)
+	if parent == nil {
+		return ""
+	}
+	parentPath := ti.originalPath(parent)
+	if parentPath == "" {
+		return ""
+	}
+	return path.Join(parentPath, n.Name)
+}
+
+// trashedDate returns the date the closest ancestor of the node in the trash
+// was trashed.
+func (ti *trashIndex) trashedDate(n *node.Node, inTrash map[string]bool) time.Time {
+	for parent := ti.parent(n); parent != nil; parent = ti.parent(parent) {
+		if inTrash[parent.Id] {
+			return parent.ModifiedDate
+		}
+	}
+	return n.ModifiedDate
+}
+
+// trashedAncestor returns the item of the closest ancestor of the node.
+func (ti *trashIndex) trashedAncestor(n *node.Node, items map[string]*TrashItem) *TrashItem {
+	for parent := ti.parent(n); parent != nil && !parent.IsRoot; parent = ti.parent(parent) {
+		if item, ok := items[parent.Id]; ok {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetTrashView(t *testing.T) {
+	server := newTestServer(t)
+	files := map[string]string{
+		"/photos/2023/IMG_0001.jpg": "0001",
+		"/photos/2023/IMG_0002.jpg": "000002",
+		"/docs/a.txt":               "a",
+	}
+	for name, content := range files {
+		if _, err := server.PutFile(name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trashedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetClock(func() time.Time { return trashedAt })
+	for _, name := range []string{"/photos/2023/IMG_0001.jpg", "/photos/2023"} {
+		if err := server.Trash(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.SetClock(func() time.Time { return trashedAt.Add(48 * time.Hour) })
+	if err := server.Trash("/docs/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, server, "")
+
+	view, err := c.GetTrashView(nil)
+	if err != nil {
+		t.Fatalf("c.GetTrashView() error: %s", err)
+	}
+	// IMG_0002.jpg is trashed with its folder.
+	if want, got := 4, view.Count; want != got {
+		t.Errorf("c.GetTrashView().Count: want %d got %d", want, got)
+	}
+	if want, got := uint64(11), view.Size; want != got {
+		t.Errorf("c.GetTrashView().Size: want %d got %d", want, got)
+	}
+	if len(view.Items) != 2 {
+		t.Fatalf("c.GetTrashView().Items: want 2 items got %d", len(view.Items))
+	}
+	if want, got := "/docs/a.txt", view.Items[0].OriginalPath; want != got {
+		t.Errorf("c.GetTrashView().Items[0].OriginalPath: want %q got %q", want, got)
+	}
+	folder := view.Items[1]
+	if want, got := "/photos/2023", folder.OriginalPath; want != got {
+		t.Errorf("c.GetTrashView().Items[1].OriginalPath: want %q got %q", want, got)
+	}
+	if len(folder.Children) != 2 || folder.Children[0].OriginalPath != "/photos/2023/IMG_0001.jpg" || folder.Children[1].OriginalPath != "/photos/2023/IMG_0002.jpg" {
+		t.Fatalf("c.GetTrashView() folder children: want IMG_0001.jpg and IMG_0002.jpg got %v", folder.Children)
+	}
+	if !folder.Children[1].TrashedDate.Equal(trashedAt) {
+		t.Errorf("c.GetTrashView() trashed date of a child trashed with its folder: want %s got %s", trashedAt, folder.Children[1].TrashedDate)
+	}
+	if want, got := uint64(10), folder.Size; want != got {
+		t.Errorf("c.GetTrashView() folder size: want %d got %d", want, got)
+	}
+	if !folder.TrashedDate.Equal(trashedAt) {
+		t.Errorf("c.GetTrashView() folder trashed date: want %s got %s", trashedAt, folder.TrashedDate)
+	}
+
+	filters := map[string]struct {
+		filter TrashFilter
+		want   []string
+	}{
+		"prefix":        {TrashFilter{PathPrefix: "/PHOTOS/"}, []string{"/photos/2023"}},
+		"nested prefix": {TrashFilter{PathPrefix: "/photos/2023/img_0001.jpg"}, []string{"/photos/2023/IMG_0001.jpg"}},
+		"not a prefix":  {TrashFilter{PathPrefix: "/doc"}, nil},
+		"after":         {TrashFilter{TrashedAfter: trashedAt.Add(time.Hour)}, []string{"/docs/a.txt"}},
+		"before":        {TrashFilter{TrashedBefore: trashedAt.Add(time.Hour)}, []string{"/photos/2023"}},
+	}
+	for name, test := range filters {
+		view, err := c.GetTrashView(&test.filter)
+		if err != nil {
+			t.Errorf("%s: c.GetTrashView() error: %s", name, err)
+			continue
+		}
+		var got []string
+		for _, item := range view.Items {
+			got = append(got, item.OriginalPath)
+		}
+		if len(got) != len(test.want) || (len(got) > 0 && got[0] != test.want[0]) {
+			t.Errorf("%s: c.GetTrashView(): want %q got %q", name, test.want, got)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package client
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/montaguethomas/acd-go/constants"
 	"github.com/montaguethomas/acd-go/log"
@@ -17,35 +22,36 @@ import (
 // Upload uploads io.Reader to the path defined by the filename. It will create
 // any non-existing folders.
 func (c *Client) Upload(filename string, overwrite bool, labels []string, properties node.Property, r io.Reader) (*node.Node, error) {
+	return c.UploadContext(context.Background(), filename, overwrite, labels, properties, r)
+}
+
+// UploadContext is like Upload but uses ctx for all the requests. Cancelling
+// ctx aborts the upload while r is being streamed.
+func (c *Client) UploadContext(ctx context.Context, filename string, overwrite bool, labels []string, properties node.Property, r io.Reader) (*node.Node, error) {
 	var (
 		err        error
-		logLevel   = log.GetLevel()
 		fileNode   *node.Node
 		parentNode *node.Node
 	)
 
-	parentNode, err = c.GetNodeTree().MkDirAll(path.Dir(filename))
+	parentNode, err = c.GetNodeTree().MkDirAllContext(ctx, path.Dir(filename))
 	if err != nil {
 		return nil, err
 	}
-	{
-		log.SetLevel(log.DisableLogLevel)
-		fileNode, err = c.GetNodeTree().FindNode(filename)
-		log.SetLevel(logLevel)
-	}
+	fileNode, err = c.GetNodeTree().LookupNode(filename)
 	if err == nil {
 		if !overwrite {
 			log.Errorf("%s: %s", constants.ErrFileExists, filename)
 			return nil, constants.ErrFileExists
 		}
-		if err = c.GetNodeTree().Overwrite(fileNode, labels, properties, r); err != nil {
+		if err = c.GetNodeTree().OverwriteContext(ctx, fileNode, labels, properties, r); err != nil {
 			return nil, err
 		}
 
 		return fileNode, nil
 	}
 
-	fileNode, err = c.GetNodeTree().Upload(parentNode, path.Base(filename), labels, properties, r)
+	fileNode, err = c.GetNodeTree().UploadContext(ctx, parentNode, path.Base(filename), labels, properties, r)
 	if err != nil {
 		return nil, err
 	}
@@ -53,90 +59,392 @@ func (c *Client) Upload(filename string, overwrite bool, labels []string, proper
 	return fileNode, nil
 }
 
+// defaultMirrorMaxDeletes is the maximum number of remote nodes trashed by a
+// mirror upload when UploadFolderOptions.MaxDeletes is not set.
+const defaultMirrorMaxDeletes = 100
+
+type (
+	// UploadFolderOptions configures UploadFolderWithOptions.
+	UploadFolderOptions struct {
+		// Recursive uploads the sub-folders of the local folder.
+		Recursive bool
+		// Overwrite replaces the remote files which content differs, an
+		// error is returned otherwise.
+		Overwrite bool
+		// Labels and Properties are set on the uploaded files.
+		Labels     []string
+		Properties node.Property
+		// Mirror makes the remote folder an exact copy of the local folder:
+		// the local folders are created even when empty, and the remote files
+		// and folders which do not exist locally are moved to the trash.
+		Mirror bool
+		// MaxDeletes is the maximum number of remote nodes a mirror upload
+		// may trash, a trashed folder counting as one. If more nodes should
+		// be trashed, constants.ErrTooManyDeletions is returned before any
+		// change is made.
+		// 100 by default, a negative value disables the limit.
+		MaxDeletes int
+		// Workers is the number of files uploaded in parallel, 1 by default.
+		// The remote folders are created one at a time.
+		Workers int
+	}
+
+	// UploadFolderSummary lists the remote paths changed by
+	// UploadFolderWithOptions.
+	UploadFolderSummary struct {
+		CreatedFolders []string
+		Uploaded       []string
+		Updated        []string
+		Deleted        []string
+	}
+)
+
 // UploadFolder uploads an entire folder.
 // If recursive is true, it will recurse through the entire filetree under
 // localPath.  If overwrite is false and an existing file with the same md5 was
 // found, an error will be returned.
 func (c *Client) UploadFolder(localPath, remotePath string, recursive, overwrite bool, labels []string, properties node.Property) error {
+	return c.UploadFolderContext(context.Background(), localPath, remotePath, recursive, overwrite, labels, properties)
+}
+
+// UploadFolderContext is like UploadFolder but uses ctx for all the requests.
+// It stops walking localPath once ctx is done.
+func (c *Client) UploadFolderContext(ctx context.Context, localPath, remotePath string, recursive, overwrite bool, labels []string, properties node.Property) error {
+	_, err := c.UploadFolderWithOptionsContext(ctx, localPath, remotePath, &UploadFolderOptions{
+		Recursive:  recursive,
+		Overwrite:  overwrite,
+		Labels:     labels,
+		Properties: properties,
+	})
+	return err
+}
+
+// UploadFolderWithOptions uploads an entire folder as configured by opts and
+// returns a summary of the changes made to remotePath, each list sorted. The
+// summary lists the changes made before an error, if any.
+func (c *Client) UploadFolderWithOptions(localPath, remotePath string, opts *UploadFolderOptions) (*UploadFolderSummary, error) {
+	return c.UploadFolderWithOptionsContext(context.Background(), localPath, remotePath, opts)
+}
+
+// UploadFolderWithOptionsContext is like UploadFolderWithOptions but uses ctx
+// for all the requests. It stops walking localPath once ctx is done.
+func (c *Client) UploadFolderWithOptionsContext(ctx context.Context, localPath, remotePath string, opts *UploadFolderOptions) (*UploadFolderSummary, error) {
 	log.Debugf("uploading %q to %q", localPath, remotePath)
-	if err := filepath.Walk(localPath, c.uploadFolderFunc(localPath, remotePath, recursive, overwrite, labels, properties)); err != nil {
-		return err
+	if opts == nil {
+		opts = &UploadFolderOptions{}
+	}
+	u := &folderUpload{
+		c:          c,
+		opts:       opts,
+		properties: opts.Properties,
+		summary:    &UploadFolderSummary{},
+		progress: newFolderProgress(ctx, func() int {
+			return countLocalFiles(localPath, opts.Recursive)
+		}),
+	}
+	if u.properties == nil {
+		u.properties = node.NewProperty()
 	}
 
-	return nil
+	// the deletions are planned, and checked against MaxDeletes, before the
+	// upload as it does not change them.
+	var deletions []*node.Node
+	var deletionPaths []string
+	if opts.Mirror {
+		localPaths, err := localMirrorPaths(localPath, opts.Recursive)
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrOpenFile, err)
+			return u.summary, constants.ErrOpenFile
+		}
+		deletions, deletionPaths, err = c.planMirrorDeletions(ctx, remotePath, opts, localPaths)
+		if err != nil {
+			return u.summary, err
+		}
+	}
+
+	err := u.run(ctx, localPath, remotePath)
+	if err == nil && opts.Mirror {
+		err = c.mirrorDeletions(ctx, deletions, deletionPaths, u.summary)
+	}
+	for _, paths := range [][]string{u.summary.CreatedFolders, u.summary.Uploaded, u.summary.Updated, u.summary.Deleted} {
+		sort.Strings(paths)
+	}
+	return u.summary, err
+}
+
+type (
+	// folderUpload walks a local folder and hands its files to a pool of
+	// upload workers.
+	folderUpload struct {
+		c          *Client
+		opts       *UploadFolderOptions
+		properties node.Property
+		progress   *folderProgress
+
+		// mutex guards summary.
+		mutex   sync.Mutex
+		summary *UploadFolderSummary
+	}
+
+	// uploadJob is a local file of size bytes to upload to remoteFilename,
+	// under parent.
+	uploadJob struct {
+		fpath          string
+		size           int64
+		remoteFilename string
+		parent         *node.Node
+		existing       *node.Node
+	}
+)
+
+// run uploads localPath to remoteBasePath. The remote folders are created by
+// the walk, the files are uploaded by opts.Workers workers. The first error
+// stops the walk and the workers.
+func (u *folderUpload) run(ctx context.Context, localPath, remoteBasePath string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		jobs     = make(chan uploadJob)
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	for i := 0; i < max(u.opts.Workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := u.upload(ctx, job); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	if err := filepath.Walk(localPath, u.walkFunc(ctx, localPath, remoteBasePath, jobs)); err != nil {
+		fail(err)
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
 }
 
-func (c *Client) uploadFolderFunc(localPath, remoteBasePath string, recursive, overwrite bool, labels []string, properties node.Property) filepath.WalkFunc {
+func (u *folderUpload) walkFunc(ctx context.Context, localPath, remoteBasePath string, jobs chan<- uploadJob) filepath.WalkFunc {
 	return func(fpath string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
 		var (
-			logLevel   = log.GetLevel()
 			fileNode   *node.Node
 			remoteNode *node.Node
-			f          *os.File
 		)
 
 		parts := strings.SplitAfter(fpath, localPath)
 		remoteFilename := remoteBasePath + strings.Join(parts[1:], "/")
 		remotePath := path.Dir(remoteFilename)
 		log.Debugf("localPath %q remotePath %q fpath %q remoteFilename %q recursive %t overwrite %t",
-			localPath, remotePath, fpath, remoteFilename, recursive, overwrite)
+			localPath, remotePath, fpath, remoteFilename, u.opts.Recursive, u.opts.Overwrite)
 
-		// is this a folder?
-		if info.IsDir() {
-			log.Debugf("%q is a folder, skipping", fpath)
-			return nil
-		}
 		// are we not recursive and trying to upload a file down the tree?
-		if !recursive && localPath != path.Dir(fpath) {
+		if !u.opts.Recursive && fpath != localPath && localPath != path.Dir(fpath) {
 			log.Debugf("%q is inside a sub-folder but we are not running recursively, skipping", fpath)
 			return nil
 		}
 
-		log.Infof("uploading %q to %q", fpath, remoteFilename)
-		if remoteNode, err = c.GetNodeTree().MkDirAll(remotePath); err != nil {
-			return err
+		// is this a folder?
+		if info.IsDir() {
+			if !u.opts.Mirror {
+				log.Debugf("%q is a folder, skipping", fpath)
+				return nil
+			}
+			if _, err := u.c.GetNodeTree().LookupNode(remoteFilename); err == nil {
+				return nil
+			}
+			if _, err := u.c.GetNodeTree().MkDirAllContext(ctx, remoteFilename); err != nil {
+				return err
+			}
+			u.mutex.Lock()
+			u.summary.CreatedFolders = append(u.summary.CreatedFolders, remoteFilename)
+			u.mutex.Unlock()
+			return nil
 		}
 
-		if f, err = os.Open(fpath); err != nil {
-			log.Errorf("%s: %s", constants.ErrOpenFile, fpath)
-			return constants.ErrOpenFile
+		if remoteNode, err = u.c.GetNodeTree().MkDirAllContext(ctx, remotePath); err != nil {
+			return err
 		}
-		defer f.Close()
 
 		// does the file already exist?
-		{
-			log.SetLevel(log.DisableLogLevel)
-			fileNode, err = c.GetNodeTree().FindNode(remoteFilename)
-			log.SetLevel(logLevel)
-		}
-		if err == nil {
-			if fileNode.IsDir() {
-				log.Errorf("%s: remoteFilename %q", constants.ErrFileExistsAndIsFolder, remoteFilename)
-				return constants.ErrFileExistsAndIsFolder
-			}
-			hash := md5.New()
-			f.Seek(0, 0)
-			io.Copy(hash, f)
-			if hex.EncodeToString(hash.Sum(nil)) == fileNode.ContentProperties.MD5 {
-				log.Debugf("%q already exists and has the same content, skipping", fpath)
-				return nil
-			}
+		fileNode, _ = u.c.GetNodeTree().LookupNode(remoteFilename)
 
-			log.Debugf("%q already exists, overwrite is %t", fpath, overwrite)
-			if !overwrite {
-				log.Errorf("%s: remoteFilename %q", constants.ErrFileExistsWithDifferentContents, remoteFilename)
-				return constants.ErrFileExistsWithDifferentContents
-			}
+		select {
+		case jobs <- uploadJob{fpath: fpath, size: info.Size(), remoteFilename: remoteFilename, parent: remoteNode, existing: fileNode}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// upload uploads the file of the job, or overwrites the existing node if its
+// content differs.
+func (u *folderUpload) upload(ctx context.Context, job uploadJob) error {
+	if err := u.uploadFile(u.progress.fileContext(ctx), job); err != nil {
+		return err
+	}
+	u.progress.fileDone(path.Base(job.fpath), job.size)
+	return nil
+}
+
+func (u *folderUpload) uploadFile(ctx context.Context, job uploadJob) error {
+	log.Infof("uploading %q to %q", job.fpath, job.remoteFilename)
+	f, err := os.Open(job.fpath)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrOpenFile, job.fpath)
+		return constants.ErrOpenFile
+	}
+	defer f.Close()
+
+	if fileNode := job.existing; fileNode != nil {
+		if fileNode.IsDir() {
+			log.Errorf("%s: remoteFilename %q", constants.ErrFileExistsAndIsFolder, job.remoteFilename)
+			return constants.ErrFileExistsAndIsFolder
+		}
+		hash := md5.New()
+		io.Copy(hash, f)
+		if hex.EncodeToString(hash.Sum(nil)) == fileNode.ContentProperties.MD5 {
+			log.Debugf("%q already exists and has the same content, skipping", job.fpath)
+			return nil
+		}
 
-			f.Seek(0, 0)
-			return c.GetNodeTree().Overwrite(fileNode, labels, properties, f)
+		log.Debugf("%q already exists, overwrite is %t", job.fpath, u.opts.Overwrite)
+		if !u.opts.Overwrite {
+			log.Errorf("%s: remoteFilename %q", constants.ErrFileExistsWithDifferentContents, job.remoteFilename)
+			return constants.ErrFileExistsWithDifferentContents
 		}
 
 		f.Seek(0, 0)
-		if _, err := c.GetNodeTree().Upload(remoteNode, path.Base(fpath), labels, properties, f); err != nil && err != constants.ErrNoContentsToUpload {
+		if err := u.c.GetNodeTree().OverwriteContext(ctx, fileNode, u.opts.Labels, u.properties, f); err != nil {
+			return err
+		}
+		u.mutex.Lock()
+		u.summary.Updated = append(u.summary.Updated, job.remoteFilename)
+		u.mutex.Unlock()
+		return nil
+	}
+
+	if _, err := u.c.GetNodeTree().UploadContext(ctx, job.parent, path.Base(job.fpath), u.opts.Labels, u.properties, f); err != nil {
+		if err == constants.ErrNoContentsToUpload {
+			return nil
+		}
+		return err
+	}
+	u.mutex.Lock()
+	u.summary.Uploaded = append(u.summary.Uploaded, job.remoteFilename)
+	u.mutex.Unlock()
+
+	return nil
+}
+
+// localMirrorPaths returns the lowercase paths, relative to root and slash
+// separated, of the files and folders under root, only those directly under
+// it if recursive is false.
+func localMirrorPaths(root string, recursive bool) (map[string]bool, error) {
+	paths := map[string]bool{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
 			return err
 		}
+		paths[strings.ToLower(filepath.ToSlash(rel))] = true
+		if d.IsDir() && !recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return paths, err
+}
 
+// planMirrorDeletions returns the nodes under remotePath which are not in
+// localPaths, the lowercase paths relative to the local folder, and their
+// paths. It returns constants.ErrTooManyDeletions if there are more than
+// opts.MaxDeletes.
+func (c *Client) planMirrorDeletions(ctx context.Context, remotePath string, opts *UploadFolderOptions, localPaths map[string]bool) ([]*node.Node, []string, error) {
+	root := strings.Trim(path.Clean("/"+remotePath), "/")
+	if root == "" {
+		root = "."
+	}
+	var deletions []*node.Node
+	var deletionPaths []string
+	err := fs.WalkDir(c.GetNodeTree().FSContext(ctx), root, func(p string, d fs.DirEntry, err error) error {
+		// the remote folder is created by the upload.
+		if p == root && errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel := p
+		if root != "." {
+			rel = strings.TrimPrefix(p, root+"/")
+		}
+		if !localPaths[strings.ToLower(rel)] {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			deletions = append(deletions, info.Sys().(*node.Node))
+			deletionPaths = append(deletionPaths, "/"+p)
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && !opts.Recursive {
+			return fs.SkipDir
+		}
 		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maxDeletes := opts.MaxDeletes
+	if maxDeletes == 0 {
+		maxDeletes = defaultMirrorMaxDeletes
 	}
+	if maxDeletes > 0 && len(deletions) > maxDeletes {
+		log.Errorf("%s: %d nodes to trash, the maximum is %d", constants.ErrTooManyDeletions, len(deletions), maxDeletes)
+		return nil, nil, constants.ErrTooManyDeletions
+	}
+	return deletions, deletionPaths, nil
+}
+
+// mirrorDeletions trashes the planned deletions.
+func (c *Client) mirrorDeletions(ctx context.Context, deletions []*node.Node, deletionPaths []string, summary *UploadFolderSummary) error {
+	for i, n := range deletions {
+		log.Infof("trashing %q which does not exist locally", deletionPaths[i])
+		if err := c.GetNodeTree().RemoveNodeContext(ctx, n); err != nil {
+			return err
+		}
+		summary.Deleted = append(summary.Deleted, deletionPaths[i])
+	}
+	return nil
 }
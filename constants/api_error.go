@@ -0,0 +1,156 @@
+package constants
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxAPIErrorBodySize is the maximum number of bytes of the response body
+// kept on an APIError.
+const maxAPIErrorBodySize = 4096
+
+// requestIdHeaders are the response headers Amazon uses to identify a request.
+var requestIdHeaders = []string{"x-amzn-RequestId", "x-amz-request-id", "x-amzn-requestid"}
+
+// APIError is returned when the Amazon Cloud Drive API responds with an error.
+// It matches the ErrResponse* error of its status code with errors.Is, e.g.
+// errors.Is(err, constants.ErrResponseDuplicateExists) for a 409 response.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Method is the HTTP method of the request.
+	Method string
+	// URL is the URL of the request, without any password.
+	URL string
+	// Code is the error code sent by Amazon, if any.
+	Code string
+	// Message is the error message sent by Amazon, if any.
+	Message string
+	// RequestId identifies the request on the Amazon side.
+	RequestId string
+	// Body is the (possibly truncated) response body.
+	Body string
+}
+
+// apiErrorBody holds the different error bodies returned by the Amazon APIs.
+type apiErrorBody struct {
+	// Amazon Drive
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Logref  string `json:"logref"`
+
+	// Login with Amazon
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	RequestId        string `json:"request_id"`
+}
+
+// NewAPIError returns the APIError for the response res with the body it has
+// already read.
+func NewAPIError(res *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: res.StatusCode,
+	}
+	if res.Request != nil {
+		e.Method = res.Request.Method
+		if res.Request.URL != nil {
+			e.URL = res.Request.URL.Redacted()
+		}
+	}
+	for _, header := range requestIdHeaders {
+		if id := res.Header.Get(header); id != "" {
+			e.RequestId = id
+			break
+		}
+	}
+
+	if len(body) > maxAPIErrorBodySize {
+		body = body[:maxAPIErrorBodySize]
+	}
+	e.Body = string(body)
+
+	var eb apiErrorBody
+	if err := json.Unmarshal(body, &eb); err == nil {
+		e.Code = eb.Code
+		if e.Code == "" {
+			e.Code = eb.Error
+		}
+		e.Message = eb.Message
+		if e.Message == "" {
+			e.Message = eb.ErrorDescription
+		}
+		if e.RequestId == "" {
+			e.RequestId = eb.RequestId
+		}
+		if e.RequestId == "" {
+			e.RequestId = eb.Logref
+		}
+	}
+
+	return e
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Unwrap().Error())
+	if e.Method != "" || e.URL != "" {
+		fmt.Fprintf(&sb, ": %s %s", e.Method, e.URL)
+	}
+	if e.Code != "" {
+		fmt.Fprintf(&sb, ": %s", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&sb, ": %s", e.Message)
+	}
+	if e.RequestId != "" {
+		fmt.Fprintf(&sb, " (request id %s)", e.RequestId)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the ErrResponse* error of the status code.
+func (e *APIError) Unwrap() error {
+	return ErrorForStatus(e.StatusCode)
+}
+
+// Is reports whether target is ErrResponseUnknown for a status which used to
+// have no ErrResponse* error of its own, so the errors.Is checks written
+// before they had one keep working. The ErrResponse* error of the status is
+// matched through Unwrap.
+func (e *APIError) Is(target error) bool {
+	if target != ErrResponseUnknown {
+		return false
+	}
+	switch e.StatusCode {
+	case http.StatusNotFound, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+// ErrorForStatus returns the ErrResponse* error for the HTTP status code.
+func ErrorForStatus(statusCode int) error {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrResponseBadInput
+	case http.StatusUnauthorized:
+		return ErrResponseInvalidToken
+	case http.StatusForbidden:
+		return ErrResponseForbidden
+	case http.StatusNotFound:
+		return ErrResponseNotFound
+	case http.StatusConflict:
+		return ErrResponseDuplicateExists
+	case http.StatusTooManyRequests:
+		return ErrResponseTooManyRequests
+	case http.StatusInternalServerError:
+		return ErrResponseInternalServerError
+	case http.StatusServiceUnavailable:
+		return ErrResponseUnavailable
+	default:
+		return ErrResponseUnknown
+	}
+}
@@ -15,8 +15,12 @@ var (
 	ErrResponseInvalidToken = errors.New("response returned with status 401")
 	// ErrResponseForbidden Forbidden.
 	ErrResponseForbidden = errors.New("response returned with status 403")
+	// ErrResponseNotFound The resource was not found.
+	ErrResponseNotFound = errors.New("response returned with status 404")
 	// ErrResponseDuplicateExists Duplicate file exists.
 	ErrResponseDuplicateExists = errors.New("response returned with status 409")
+	// ErrResponseTooManyRequests Too many requests, the client is throttled.
+	ErrResponseTooManyRequests = errors.New("response returned with status 429")
 	// ErrResponseInternalServerError Servers are not working as expected. The
 	// request is probably valid but needs to be requested again later.
 	ErrResponseInternalServerError = errors.New("response returned with status 500")
@@ -43,6 +47,12 @@ var (
 
 	// ErrNodeDownload is returned if there was an error downloading the file.
 	ErrNodeDownload = errors.New("error downloading the node")
+	// ErrInvalidRange is returned when reading or seeking outside of the
+	// content of a file.
+	ErrInvalidRange = errors.New("invalid range")
+	// ErrMD5Mismatch is returned when the md5 of the downloaded content does
+	// not match the md5 of the node.
+	ErrMD5Mismatch = errors.New("the md5 of the downloaded content does not match")
 
 	// Uploading errors
 
@@ -64,6 +74,15 @@ var (
 	ErrWritingFileContents = errors.New("error writing the file contents")
 	// ErrNoContentsToUpload is returned if the reader does not even have one byte.
 	ErrNoContentsToUpload = errors.New("reader has not contents to upload")
+	// ErrTooManyDeletions is returned if a mirror upload would trash more
+	// remote nodes than allowed.
+	ErrTooManyDeletions = errors.New("too many remote nodes to delete")
+
+	// Sync errors
+
+	// ErrUnknownConflictPolicy is returned if a folder sync is configured with
+	// an unknown conflict policy.
+	ErrUnknownConflictPolicy = errors.New("unknown conflict policy")
 
 	// JSON errors
 
@@ -87,19 +106,40 @@ var (
 	ErrCannotCreateRootNode = errors.New("root node cannot be created")
 	// ErrCacheFileConfigEmpty is returned when a client config does not set the cacheFile
 	ErrCacheFileConfigEmpty = errors.New("cache file config must be set")
+	// ErrInvalidBandwidthLimit is returned when a client config sets a
+	// negative bandwidth rate.
+	ErrInvalidBandwidthLimit = errors.New("bandwidth rates cannot be negative")
 	// ErrLoadingCache is returned when an error happens while loading from cacheFile
 	ErrLoadingCache = errors.New("error loading from the cache file")
+	// ErrCacheCorrupt is returned with ErrLoadingCache when the cache file is
+	// truncated or does not match its checksum.
+	ErrCacheCorrupt = errors.New("the cache file is corrupt")
+	// ErrCacheOutdated is returned with ErrLoadingCache when the format version
+	// of the cache file cannot be migrated to the current one.
+	ErrCacheOutdated = errors.New("the cache file format version is not supported")
 	// ErrMustFetchFresh is returned if the changes API requested a change.
 	ErrMustFetchFresh = errors.New("must refresh the node tree")
 	// ErrCannotCreateANodeUnderAFile is returned if you attempt to create a
 	// folder/file under an existing file.
 	ErrCannotCreateANodeUnderAFile = errors.New("cannot create a node under a file")
+	// ErrCannotMoveRootNode is returned if you attempt to move or rename the
+	// root node.
+	ErrCannotMoveRootNode = errors.New("root node cannot be moved or renamed")
+	// ErrMoveIntoDescendant is returned if you attempt to move a folder under
+	// itself or one of its descendants.
+	ErrMoveIntoDescendant = errors.New("cannot move a folder under itself or one of its descendants")
+	// ErrInvalidNodeName is returned when a node name is empty or contains a
+	// slash.
+	ErrInvalidNodeName = errors.New("node name is invalid")
 	// ErrNodePropertyInvalidKey is returned when a node property key is invalid.
 	ErrNodePropertyInvalidKey = errors.New("node property key is invalid")
 	// ErrNodePropertyMaxKeys is returned when a node property cannot add anymore keys.
 	ErrNodePropertyMaxKeys = errors.New("node property has reached maximum allowed keys")
 	// ErrNodePropertyInvalidValue is returned when a node property value is invalid.
 	ErrNodePropertyInvalidValue = errors.New("node property value is invalid")
+	// ErrUnknownQuerySort is returned when a node query sorts by an unknown
+	// field.
+	ErrUnknownQuerySort = errors.New("unknown node query sort")
 
 	// URL errors
 
@@ -126,4 +166,6 @@ var (
 	ErrPathIsFolder = errors.New("path is a folder")
 	// ErrWrongPermissions is returned if the file has the wrong permissions.
 	ErrWrongPermissions = errors.New("file has wrong permissions")
+	// ErrFileClosed is returned when using a file which has been closed.
+	ErrFileClosed = errors.New("file already closed")
 )
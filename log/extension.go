@@ -6,6 +6,7 @@ package log
 import (
 	"fmt"
 	stdLog "log"
+	"sync/atomic"
 )
 
 // Level is a custom type representing a log level.
@@ -33,7 +34,7 @@ const (
 
 var (
 	// Level defines the log level. Default: Error
-	level = ErrorLevel
+	level = newAtomicLevel(ErrorLevel)
 
 	levelPrefix = map[Level]string{
 		DisableLogLevel: "",
@@ -59,12 +60,20 @@ func Levels() string {
 
 // SetLevel sets the log level to l.
 func SetLevel(l Level) {
-	level = l
+	level.Store(uint32(l))
 }
 
 // GetLevel sets the log level to l.
 func GetLevel() Level {
-	return level
+	return Level(level.Load())
+}
+
+// newAtomicLevel returns l stored in an atomic value so the level can be
+// changed while other goroutines are logging.
+func newAtomicLevel(l Level) *atomic.Uint32 {
+	v := new(atomic.Uint32)
+	v.Store(uint32(l))
+	return v
 }
 
 // Printf calls Printf only if the level is equal or lower than the set level.
@@ -75,7 +84,7 @@ func Printf(l Level, format string, v ...interface{}) {
 		return
 	}
 
-	if l <= level {
+	if l <= GetLevel() {
 		defer stdLog.SetPrefix(stdLog.Prefix())
 		stdLog.SetPrefix(levelPrefix[l])
 		stdLog.Printf(format, v...)
@@ -90,7 +99,7 @@ func Print(l Level, v ...interface{}) {
 		return
 	}
 
-	if l <= level {
+	if l <= GetLevel() {
 		defer stdLog.SetPrefix(stdLog.Prefix())
 		stdLog.SetPrefix(levelPrefix[l])
 		stdLog.Print(v...)
@@ -1,50 +1,237 @@
 package node
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/gob"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/montaguethomas/acd-go/constants"
 	"github.com/montaguethomas/acd-go/log"
 )
 
+// The cache file starts with a header made of cacheMagic, the format version
+// and the length and the SHA-256 checksum of the payload which follows, the
+// gob-encoded Tree. The files of version 1 have no header, they are the
+// payload alone.
+const (
+	cacheMagic      = "ACDCACHE"
+	cacheVersion    = 2
+	cacheHeaderSize = len(cacheMagic) + 4 + 8 + sha256.Size
+)
+
+// cacheMigrations convert a payload of a version to the payload of the next
+// version. A change of the fields of the Tree or of the Node which gob cannot
+// decode from the previous payloads must increment cacheVersion and add the
+// migration of the previous version.
+var cacheMigrations = map[uint32]func(payload []byte) ([]byte, error){
+	// version 2 added the header, the payload is the same.
+	1: func(payload []byte) ([]byte, error) { return payload, nil },
+}
+
+// cacheHeader is the header of a cache file.
+type cacheHeader struct {
+	version  uint32
+	length   uint64
+	checksum [sha256.Size]byte
+}
+
+func (h *cacheHeader) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, cacheHeaderSize)
+	b = append(b, cacheMagic...)
+	b = binary.BigEndian.AppendUint32(b, h.version)
+	b = binary.BigEndian.AppendUint64(b, h.length)
+	return append(b, h.checksum[:]...), nil
+}
+
+func (h *cacheHeader) UnmarshalBinary(b []byte) error {
+	if len(b) != cacheHeaderSize || string(b[:len(cacheMagic)]) != cacheMagic {
+		return constants.ErrCacheCorrupt
+	}
+	b = b[len(cacheMagic):]
+	h.version = binary.BigEndian.Uint32(b)
+	h.length = binary.BigEndian.Uint64(b[4:])
+	copy(h.checksum[:], b[12:])
+	return nil
+}
+
 func (nt *Tree) loadCache() error {
 	log.Debug("node.Tree loadCache starting.")
 	defer log.Debug("node.Tree loadCache completed.")
 
-	f, err := os.Open(nt.cacheFile)
-	if err != nil {
+	data, err := os.ReadFile(nt.cacheFile)
+	if err != nil || len(data) == 0 {
 		log.Debugf("error opening the cache file %q: %s", nt.cacheFile, constants.ErrLoadingCache)
 		return constants.ErrLoadingCache
 	}
-	nt.Lock()
-	// using defer here causes a deadlock with nt.buildNodeIdMap()
-	if err := gob.NewDecoder(f).Decode(nt); err != nil {
-		nt.Unlock()
-		log.Debugf("error decoding the cache file %q: %s", nt.cacheFile, err)
-		return constants.ErrLoadingCache
+	payload, err := readCachePayload(data)
+	if err != nil {
+		log.Errorf("%s %q: %s", constants.ErrLoadingCache, nt.cacheFile, err)
+		return fmt.Errorf("%w: %w", constants.ErrLoadingCache, err)
+	}
+
+	var decoded Tree
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&decoded); err != nil || decoded.Node == nil {
+		log.Errorf("%s %q: %s: %v", constants.ErrLoadingCache, nt.cacheFile, constants.ErrCacheCorrupt, err)
+		return fmt.Errorf("%w: %w", constants.ErrLoadingCache, constants.ErrCacheCorrupt)
 	}
+	nt.Lock()
+	nt.Node = decoded.Node
+	nt.LastUpdated = decoded.LastUpdated
+	nt.Checkpoint = decoded.Checkpoint
 	nt.Unlock()
 	log.Debugf("loaded NodeTree from cache file %q.", nt.cacheFile)
 	nt.buildNodeIdMap(nt.Node)
+	nt.resetIndex()
 	return nil
 }
 
+// readCachePayload returns the payload of the cache file data, checked and
+// migrated to the current version. It returns constants.ErrCacheCorrupt if
+// the data is not a complete cache file and constants.ErrCacheOutdated if its
+// version cannot be migrated.
+func readCachePayload(data []byte) ([]byte, error) {
+	version, payload := uint32(1), data
+	if bytes.HasPrefix(data, []byte(cacheMagic)) {
+		if len(data) < cacheHeaderSize {
+			return nil, constants.ErrCacheCorrupt
+		}
+		var header cacheHeader
+		if err := header.UnmarshalBinary(data[:cacheHeaderSize]); err != nil {
+			return nil, err
+		}
+		payload = data[cacheHeaderSize:]
+		if uint64(len(payload)) != header.length || sha256.Sum256(payload) != header.checksum {
+			return nil, constants.ErrCacheCorrupt
+		}
+		version = header.version
+	}
+
+	for ; version < cacheVersion; version++ {
+		migrate, ok := cacheMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d", constants.ErrCacheOutdated, version)
+		}
+		var err error
+		if payload, err = migrate(payload); err != nil {
+			return nil, fmt.Errorf("%w: migrating version %d: %w", constants.ErrCacheCorrupt, version, err)
+		}
+	}
+	if version != cacheVersion {
+		return nil, fmt.Errorf("%w: version %d", constants.ErrCacheOutdated, version)
+	}
+	return payload, nil
+}
+
+// saveCache writes the tree to a temporary file next to the cache file and
+// renames it over the cache file once synced, so a crash never leaves a
+// partial cache. A cache file which is not a regular file, such as /dev/null,
+// is written in place.
 func (nt *Tree) saveCache() error {
 	log.Debug("node.Tree saveCache starting.")
 	defer log.Debug("node.Tree saveCache completed.")
 
-	f, err := os.Create(nt.cacheFile)
+	target := nt.cacheFile
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+	if fi, err := os.Stat(target); err == nil && !fi.Mode().IsRegular() {
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrCreateFile, nt.cacheFile)
+			return constants.ErrCreateFile
+		}
+		defer f.Close()
+		return nt.writeCache(f)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".tmp*")
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreateFile, nt.cacheFile)
 		return constants.ErrCreateFile
 	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if err := nt.writeCache(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	if err := f.Close(); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	// os.CreateTemp creates the file readable by its owner only.
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(target); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	os.Chmod(tmp, mode)
+	if err := os.Rename(tmp, target); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	// the rename is only durable once the folder is synced.
+	if dir, err := os.Open(filepath.Dir(target)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	log.Debugf("saved NodeTree to cache file %q.", nt.cacheFile)
+	return nil
+}
+
+// writeCache writes the header and the payload of the cache to f. The header
+// is written with a zero checksum first, then again once the payload is
+// written.
+func (nt *Tree) writeCache(f *os.File) error {
+	header := &cacheHeader{version: cacheVersion}
+	b, _ := header.MarshalBinary()
+	if _, err := f.Write(b); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	counter := &countingWriter{w: f}
+	h := sha256.New()
+	if err := nt.encodeCache(io.MultiWriter(counter, h)); err != nil {
+		return err
+	}
+	header.length = counter.n
+	copy(header.checksum[:], h.Sum(nil))
+	b, _ = header.MarshalBinary()
+	if _, err := f.WriteAt(b, 0); err != nil {
+		log.Errorf("%s: %s", constants.ErrCreateFile, err)
+		return constants.ErrCreateFile
+	}
+	return nil
+}
+
+// encodeCache gob-encodes the tree to w.
+func (nt *Tree) encodeCache(w io.Writer) error {
 	nt.Lock()
 	defer nt.Unlock()
-	if err := gob.NewEncoder(f).Encode(nt); err != nil {
+	if err := gob.NewEncoder(w).Encode(nt); err != nil {
 		log.Errorf("%s: %s", constants.ErrGOBEncoding, err)
 		return constants.ErrGOBEncoding
 	}
-	log.Debugf("saved NodeTree to cache file %q.", nt.cacheFile)
 	return nil
 }
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += uint64(n)
+	return n, err
+}
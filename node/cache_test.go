@@ -0,0 +1,102 @@
+package node
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+func TestCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "acd-cache")
+	nt := newWalkTree("/photos/", "/photos/a.jpg", "/notes.txt")
+	nt.cacheFile = cacheFile
+	nt.Checkpoint = "checkpoint"
+	if err := nt.saveCache(); err != nil {
+		t.Fatalf("saveCache() error: %s", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(cacheFile)); len(entries) != 1 {
+		t.Errorf("saveCache() left temporary files: %v", entries)
+	}
+	data, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(data []byte) (*Tree, error) {
+		t.Helper()
+		if err := os.WriteFile(cacheFile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		loaded := &Tree{cacheFile: cacheFile, nodeIdMap: make(map[string]*Node)}
+		return loaded, loaded.loadCache()
+	}
+
+	loaded, err := load(data)
+	if err != nil {
+		t.Fatalf("loadCache() error: %s", err)
+	}
+	if loaded.Checkpoint != "checkpoint" {
+		t.Errorf("loadCache() Checkpoint: want %q got %q", "checkpoint", loaded.Checkpoint)
+	}
+	if _, err := loaded.findNode("/photos/a.jpg"); err != nil {
+		t.Errorf("loadCache() findNode(/photos/a.jpg) error: %s", err)
+	}
+
+	// version 1 is the gob-encoded tree without a header.
+	var legacy bytes.Buffer
+	if err := gob.NewEncoder(&legacy).Encode(nt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := load(legacy.Bytes()); err != nil {
+		t.Errorf("loadCache() version 1 error: %s", err)
+	}
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-1] ^= 0xff
+	newer := bytes.Clone(data)
+	binary.BigEndian.PutUint32(newer[len(cacheMagic):], cacheVersion+1)
+	tests := map[string]struct {
+		data []byte
+		want error
+	}{
+		"truncated":      {data[:len(data)-10], constants.ErrCacheCorrupt},
+		"short header":   {data[:cacheHeaderSize-1], constants.ErrCacheCorrupt},
+		"checksum":       {corrupt, constants.ErrCacheCorrupt},
+		"not gob":        {[]byte("not a cache file"), constants.ErrCacheCorrupt},
+		"newer version":  {newer, constants.ErrCacheOutdated},
+		"missing header": {data[cacheHeaderSize:], nil},
+	}
+	for name, test := range tests {
+		_, err := load(test.data)
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: loadCache() error: %s", name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, constants.ErrLoadingCache) || !errors.Is(err, test.want) {
+			t.Errorf("%s: loadCache(): want %s got %v", name, test.want, err)
+		}
+	}
+}
+
+func TestCacheNotRegularFile(t *testing.T) {
+	fi, err := os.Stat(os.DevNull)
+	if err != nil || fi.Mode().IsRegular() {
+		t.Skipf("%s is not a device", os.DevNull)
+	}
+	nt := newWalkTree("/notes.txt")
+	nt.cacheFile = os.DevNull
+	if err := nt.saveCache(); err != nil {
+		t.Fatalf("saveCache() error: %s", err)
+	}
+	if fi, err := os.Stat(os.DevNull); err != nil || fi.Mode().IsRegular() {
+		t.Errorf("saveCache() replaced %s", os.DevNull)
+	}
+}
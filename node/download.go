@@ -1,6 +1,8 @@
 package node
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,24 +14,112 @@ import (
 // Download downloads the node and returns the body as io.ReadCloser or an
 // error. The caller is responsible for closing the reader.
 func (nt *Tree) Download(n *Node) (io.ReadCloser, error) {
+	return nt.DownloadContext(context.Background(), n)
+}
+
+// DownloadContext is like Download but uses ctx for the request. Cancelling
+// ctx also aborts reading the returned body.
+func (nt *Tree) DownloadContext(ctx context.Context, n *Node) (io.ReadCloser, error) {
+	if n.IsDir() {
+		log.Errorf("%s: cannot download a folder", constants.ErrPathIsFolder)
+		return nil, constants.ErrPathIsFolder
+	}
+	url := nt.client.GetContentURL(fmt.Sprintf("nodes/%s/content", n.Id))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return nil, constants.ErrCreatingHTTPRequest
+	}
+	res, err := nt.client.Do(req)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := nt.client.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	return nt.newBodyReader(ctx, res.Body, n.Name, int64(n.Size())), nil
+}
+
+// DownloadRange downloads length bytes of the node starting at offset and
+// returns the body as io.ReadCloser or an error. A negative length reads until
+// the end of the content. The caller is responsible for closing the reader.
+func (nt *Tree) DownloadRange(n *Node, offset, length int64) (io.ReadCloser, error) {
+	return nt.DownloadRangeContext(context.Background(), n, offset, length)
+}
+
+// DownloadRangeContext is like DownloadRange but uses ctx for the request.
+// Cancelling ctx also aborts reading the returned body.
+func (nt *Tree) DownloadRangeContext(ctx context.Context, n *Node, offset, length int64) (io.ReadCloser, error) {
 	if n.IsDir() {
 		log.Errorf("%s: cannot download a folder", constants.ErrPathIsFolder)
 		return nil, constants.ErrPathIsFolder
 	}
+	if offset < 0 {
+		log.Errorf("%s: negative offset %d", constants.ErrInvalidRange, offset)
+		return nil, constants.ErrInvalidRange
+	}
+	if length == 0 || (offset > 0 && uint64(offset) >= n.Size()) {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
 	url := nt.client.GetContentURL(fmt.Sprintf("nodes/%s/content", n.Id))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return nil, constants.ErrCreatingHTTPRequest
 	}
+	if length < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
 	res, err := nt.client.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return nil, constants.ErrDoingHTTPRequest
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		res.Body.Close()
+		return io.NopCloser(bytes.NewReader(nil)), nil
 	}
 	if err := nt.client.CheckResponse(res); err != nil {
 		return nil, err
 	}
 
-	return res.Body, nil
+	// The server ignored the Range header and sent the entire content.
+	if res.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, res.Body, offset); err != nil {
+			res.Body.Close()
+			log.Errorf("%s: %s", constants.ErrReadingResponseBody, err)
+			return nil, constants.ErrReadingResponseBody
+		}
+		if length >= 0 {
+			return nt.newBodyReader(ctx, &readCloser{Reader: io.LimitReader(res.Body, length), Closer: res.Body}, n.Name, length), nil
+		}
+	}
+
+	total := int64(n.Size()) - offset
+	if length >= 0 {
+		total = min(length, total)
+	}
+	return nt.newBodyReader(ctx, res.Body, n.Name, total), nil
+}
+
+// readCloser combines an io.Reader with the io.Closer of its source.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// newBodyReader returns the body of a download of total bytes limited by the
+// download Limiter and reporting its progress to the ProgressFunc of ctx.
+func (nt *Tree) newBodyReader(ctx context.Context, body io.ReadCloser, name string, total int64) io.ReadCloser {
+	limiter := nt.client.GetDownloadLimiter()
+	if limiter == nil && progressFunc(ctx) == nil {
+		return body
+	}
+	r := newLimitedReader(ctx, body, limiter)
+	return &readCloser{Reader: newProgressReader(ctx, r, name, total), Closer: body}
 }
@@ -0,0 +1,203 @@
+package node
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// EventType is the kind of change of an Event.
+type EventType int
+
+const (
+	// EventCreated is a node added to the tree.
+	EventCreated EventType = iota + 1
+	// EventModified is a change of the labels, the description or the
+	// properties of a node.
+	EventModified
+	// EventContentChanged is a change of the content of a file.
+	EventContentChanged
+	// EventMoved is a change of the name or of the parents of a node.
+	EventMoved
+	// EventTrashed is a node moved to the trash, and removed from the tree.
+	EventTrashed
+	// EventPurged is a node deleted permanently.
+	EventPurged
+)
+
+var eventTypeNames = map[EventType]string{
+	EventCreated:        "created",
+	EventModified:       "modified",
+	EventContentChanged: "content changed",
+	EventMoved:          "moved",
+	EventTrashed:        "trashed",
+	EventPurged:         "purged",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+type (
+	// Event is a change of a node of the tree, made by Sync or by a method
+	// of the Tree.
+	Event struct {
+		Type EventType
+		// Old is a copy of the node before the change, nil for EventCreated
+		// and for an EventPurged of a node which was not in the tree.
+		Old *Node
+		// New is the node after the change. It is a copy for EventTrashed and
+		// EventPurged as the node is no longer in the tree.
+		New *Node
+		// OldPath is the path of the node before the change, empty for
+		// EventCreated.
+		OldPath string
+		// Path is the path of the node after the change, empty for
+		// EventTrashed and EventPurged.
+		Path string
+	}
+
+	// EventFunc receives the events of a tree. It is called by the
+	// goroutine which made the change, after the change, and must not block.
+	EventFunc func(Event)
+)
+
+// Subscribe calls fn with every change of the tree until the returned
+// function is called.
+func (nt *Tree) Subscribe(fn EventFunc) (unsubscribe func()) {
+	nt.eventsMu.Lock()
+	defer nt.eventsMu.Unlock()
+	if nt.subscribers == nil {
+		nt.subscribers = make(map[int]EventFunc)
+	}
+	id := nt.nextSubscriber
+	nt.nextSubscriber++
+	nt.subscribers[id] = fn
+	return func() {
+		nt.eventsMu.Lock()
+		defer nt.eventsMu.Unlock()
+		delete(nt.subscribers, id)
+	}
+}
+
+// hasSubscribers returns whether the events need to be computed.
+func (nt *Tree) hasSubscribers() bool {
+	nt.eventsMu.Lock()
+	defer nt.eventsMu.Unlock()
+	return len(nt.subscribers) > 0
+}
+
+func (nt *Tree) emit(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	nt.eventsMu.Lock()
+	subscribers := make([]EventFunc, 0, len(nt.subscribers))
+	for _, fn := range nt.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	nt.eventsMu.Unlock()
+	for _, event := range events {
+		for _, fn := range subscribers {
+			fn(event)
+		}
+	}
+}
+
+// emitCreated emits the EventCreated of n.
+func (nt *Tree) emitCreated(n *Node) {
+	if !nt.hasSubscribers() {
+		return
+	}
+	nt.emit(Event{Type: EventCreated, New: n, Path: nt.nodePath(n)})
+}
+
+// emitRemoved emits the EventTrashed or EventPurged of n, whose state before
+// the change was old at oldPath.
+func (nt *Tree) emitRemoved(old, n *Node, oldPath string) {
+	nt.emit(removedEvent(old, n, oldPath))
+}
+
+// removedEvent returns the EventTrashed or EventPurged of n.
+func removedEvent(old, n *Node, oldPath string) Event {
+	eventType := EventTrashed
+	if n.Status == StatusPurged {
+		eventType = EventPurged
+	}
+	return Event{Type: eventType, Old: old, New: n, OldPath: oldPath}
+}
+
+// emitChanges emits the events of the changes of n since old, its state at
+// oldPath.
+func (nt *Tree) emitChanges(old, n *Node, oldPath string) {
+	if old == nil {
+		return
+	}
+	newPath := nt.nodePath(n)
+	n.RLock()
+	var events []Event
+	event := func(t EventType) Event {
+		return Event{Type: t, Old: old, New: n, OldPath: oldPath, Path: newPath}
+	}
+	if oldPath != newPath || old.Name != n.Name || !slices.Equal(old.Parents, n.Parents) {
+		events = append(events, event(EventMoved))
+	}
+	if old.ContentProperties.MD5 != n.ContentProperties.MD5 || old.ContentProperties.Version != n.ContentProperties.Version ||
+		old.ContentProperties.Size != n.ContentProperties.Size {
+		events = append(events, event(EventContentChanged))
+	}
+	if !slices.Equal(old.Labels, n.Labels) || old.Description != n.Description || !reflect.DeepEqual(old.Properties, n.Properties) {
+		events = append(events, event(EventModified))
+	}
+	n.RUnlock()
+	nt.emit(events...)
+}
+
+// snapshot returns a copy of the node without its children, or nil if the
+// tree has no subscribers and the copy is not needed.
+func (nt *Tree) snapshot(n *Node) *Node {
+	if n == nil || !nt.hasSubscribers() {
+		return nil
+	}
+	n.RLock()
+	defer n.RUnlock()
+	return &Node{
+		ETagResponse:      n.ETagResponse,
+		Id:                n.Id,
+		Name:              n.Name,
+		Kind:              n.Kind,
+		Version:           n.Version,
+		ModifiedDate:      n.ModifiedDate,
+		CreatedDate:       n.CreatedDate,
+		Labels:            slices.Clone(n.Labels),
+		Description:       n.Description,
+		CreatedBy:         n.CreatedBy,
+		Parents:           slices.Clone(n.Parents),
+		Status:            n.Status,
+		Properties:        maps.Clone(n.Properties),
+		Restricted:        n.Restricted,
+		IsRoot:            n.IsRoot,
+		IsShared:          n.IsShared,
+		TempLink:          n.TempLink,
+		ContentProperties: n.ContentProperties,
+	}
+}
+
+// before returns a copy of the node and its path before a change, or nil if
+// the tree has no subscribers.
+func (nt *Tree) before(n *Node) (old *Node, oldPath string) {
+	if old = nt.snapshot(n); old == nil {
+		return nil, ""
+	}
+	return old, nt.nodePath(n)
+}
+
+// nodePath returns the path of the node, or an empty string if it is not in
+// the tree.
+func (nt *Tree) nodePath(n *Node) string {
+	p, _ := nt.PathOf(n)
+	return p
+}
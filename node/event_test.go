@@ -0,0 +1,88 @@
+package node_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Subscribe(t *testing.T) {
+	c, server := newTestClient(t)
+	tree := c.GetNodeTree()
+	var events []node.Event
+	unsubscribe := tree.Subscribe(func(e node.Event) {
+		events = append(events, e)
+	})
+	type summary struct {
+		Type    node.EventType
+		OldPath string
+		Path    string
+	}
+	takeEvents := func() []summary {
+		var got []summary
+		for _, e := range events {
+			got = append(got, summary{e.Type, e.OldPath, e.Path})
+		}
+		events = nil
+		return got
+	}
+
+	t.Run("local changes", func(t *testing.T) {
+		n, err := c.Upload("/docs/a.txt", false, nil, node.NewProperty(), bytes.NewBufferString("a"))
+		require.NoError(t, err)
+		assert.Equal(t, []summary{
+			{node.EventCreated, "", "/docs"},
+			{node.EventCreated, "", "/docs/a.txt"},
+		}, takeEvents())
+
+		require.NoError(t, tree.Overwrite(n, []string{"draft"}, node.NewProperty(), bytes.NewBufferString("aa")))
+		got := events
+		assert.Equal(t, []summary{
+			{node.EventContentChanged, "/docs/a.txt", "/docs/a.txt"},
+			{node.EventModified, "/docs/a.txt", "/docs/a.txt"},
+		}, takeEvents())
+		require.Len(t, got, 2)
+		assert.Equal(t, uint64(1), got[0].Old.Size())
+		assert.Equal(t, uint64(2), got[0].New.Size())
+
+		require.NoError(t, tree.Rename(n, "b.txt"))
+		assert.Equal(t, []summary{{node.EventMoved, "/docs/a.txt", "/docs/b.txt"}}, takeEvents())
+
+		require.NoError(t, tree.RemoveNode(n))
+		got = events
+		assert.Equal(t, []summary{{node.EventTrashed, "/docs/b.txt", ""}}, takeEvents())
+		assert.Equal(t, node.StatusTrash, got[0].New.Status)
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		_, err := server.PutFile("/docs/remote.txt", []byte("remote"))
+		require.NoError(t, err)
+		require.NoError(t, tree.Sync())
+		assert.Equal(t, []summary{{node.EventCreated, "", "/docs/remote.txt"}}, takeEvents())
+
+		_, err = server.PutFile("/docs/remote.txt", []byte("changed"))
+		require.NoError(t, err)
+		require.NoError(t, tree.Sync())
+		assert.Equal(t, []summary{{node.EventContentChanged, "/docs/remote.txt", "/docs/remote.txt"}}, takeEvents())
+
+		require.NoError(t, server.Trash("/docs/remote.txt"))
+		require.NoError(t, tree.Sync())
+		assert.Equal(t, []summary{{node.EventTrashed, "/docs/remote.txt", ""}}, takeEvents())
+
+		require.NoError(t, c.PurgeTrash())
+		require.NoError(t, tree.Sync())
+		purged := takeEvents()
+		require.NotEmpty(t, purged)
+		for _, e := range purged {
+			assert.Equal(t, node.EventPurged, e.Type)
+		}
+	})
+
+	unsubscribe()
+	_, err := c.Upload("/docs/c.txt", false, nil, node.NewProperty(), bytes.NewBufferString("c"))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
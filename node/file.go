@@ -0,0 +1,210 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// readAheadSize is the size of the buffer of the stream used for sequential
+// reads. Forward seeks within that distance reuse the stream.
+const readAheadSize = 1 << 20
+
+// File is a read-only handle on the content of a file node, returned by
+// Tree.Open. It implements io.ReadSeekCloser and io.ReaderAt using HTTP Range
+// requests.
+//
+// Sequential reads share a single streamed response, read ahead through a
+// buffer, and is only re-opened when seeking elsewhere. ReadAt issues an
+// independent request for each call and is safe for concurrent use.
+type File struct {
+	ctx  context.Context
+	tree *Tree
+	node *Node
+	size int64
+
+	mutex      sync.Mutex
+	offset     int64
+	body       io.ReadCloser
+	reader     *bufio.Reader
+	bodyOffset int64
+	closed     bool
+}
+
+// Open returns a File to read the content of the node.
+func (nt *Tree) Open(n *Node) (*File, error) {
+	return nt.OpenContext(context.Background(), n)
+}
+
+// OpenContext is like Open but uses ctx for all the requests made by the File.
+func (nt *Tree) OpenContext(ctx context.Context, n *Node) (*File, error) {
+	if n.IsDir() {
+		log.Errorf("%s: cannot open a folder", constants.ErrPathIsFolder)
+		return nil, constants.ErrPathIsFolder
+	}
+	return &File{
+		ctx:  ctx,
+		tree: nt,
+		node: n,
+		size: int64(n.Size()),
+	}, nil
+}
+
+// Node returns the node of the file.
+func (f *File) Node() *Node {
+	return f.node
+}
+
+// Size returns the size of the content of the file.
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// Read implements io.Reader.
+func (f *File) Read(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return 0, constants.ErrFileClosed
+	}
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := f.seekStream(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	f.bodyOffset += int64(n)
+	if err == io.EOF {
+		f.closeStream()
+		if f.offset < f.size {
+			return n, io.ErrUnexpectedEOF
+		}
+		if n > 0 {
+			err = nil
+		}
+	}
+	return n, err
+}
+
+// seekStream makes sure the stream is positioned at the offset of the file,
+// skipping short distances forward and re-opening it otherwise. The mutex must
+// be held by the caller.
+func (f *File) seekStream() error {
+	if f.reader != nil && f.bodyOffset != f.offset {
+		skip := f.offset - f.bodyOffset
+		if skip > 0 && skip <= readAheadSize {
+			discarded, err := f.reader.Discard(int(skip))
+			f.bodyOffset += int64(discarded)
+			if err != nil {
+				f.closeStream()
+			}
+		} else {
+			f.closeStream()
+		}
+	}
+	if f.reader != nil {
+		return nil
+	}
+
+	body, err := f.tree.DownloadRangeContext(f.ctx, f.node, f.offset, -1)
+	if err != nil {
+		return err
+	}
+	f.body = body
+	f.reader = bufio.NewReaderSize(body, readAheadSize)
+	f.bodyOffset = f.offset
+	return nil
+}
+
+// closeStream closes the stream used for sequential reads. The mutex must be
+// held by the caller.
+func (f *File) closeStream() {
+	if f.body != nil {
+		f.body.Close()
+	}
+	f.body = nil
+	f.reader = nil
+}
+
+// Seek implements io.Seeker. Seeking does not make any request, the stream is
+// re-positioned by the next Read.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return 0, constants.ErrFileClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, constants.ErrInvalidRange
+	}
+	if offset < 0 {
+		return 0, constants.ErrInvalidRange
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	closed := f.closed
+	f.mutex.Unlock()
+	if closed {
+		return 0, constants.ErrFileClosed
+	}
+	if off < 0 {
+		return 0, constants.ErrInvalidRange
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	length := min(int64(len(p)), f.size-off)
+	body, err := f.tree.DownloadRangeContext(f.ctx, f.node, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p[:length])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return n, err
+	}
+	if int64(n) < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close implements io.Closer.
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.closed {
+		return constants.ErrFileClosed
+	}
+	f.closed = true
+	f.closeStream()
+	return nil
+}
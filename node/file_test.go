@@ -0,0 +1,105 @@
+package node_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/acdtest"
+	"github.com/montaguethomas/acd-go/client"
+	"github.com/montaguethomas/acd-go/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestClient returns a client for a new fake server which is closed, with
+// the client, at the end of the test.
+func newTestClient(t *testing.T) (*client.Client, *acdtest.Server) {
+	t.Helper()
+	server := acdtest.NewServer()
+	t.Cleanup(server.Close)
+	c, err := client.New(&client.Config{
+		CacheFile:    filepath.Join(t.TempDir(), "acd-cache"),
+		EndpointURL:  server.EndpointURL(),
+		RefreshToken: server.RefreshToken,
+		SyncInterval: "1h",
+		TokenURL:     server.TokenURL(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c, server
+}
+
+func Test_File(t *testing.T) {
+	c, server := newTestClient(t)
+	content := make([]byte, 3<<20+123)
+	rand.New(rand.NewSource(1)).Read(content)
+	n, err := c.Upload("/video.bin", false, nil, node.NewProperty(), bytes.NewReader(content))
+	require.NoError(t, err)
+
+	t.Run("sequential reads share one request", func(t *testing.T) {
+		f, err := c.GetNodeTree().Open(n)
+		require.NoError(t, err)
+		defer f.Close()
+
+		requests := server.Requests()
+		got, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+		assert.Equal(t, 1, server.Requests()-requests)
+	})
+
+	t.Run("seek and read the end", func(t *testing.T) {
+		f, err := c.GetNodeTree().Open(n)
+		require.NoError(t, err)
+		defer f.Close()
+
+		offset, err := f.Seek(-1000, io.SeekEnd)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(content)-1000), offset)
+		got, err := io.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, content[len(content)-1000:], got)
+
+		// forward seek within the read-ahead reuses the stream.
+		_, err = f.Seek(10, io.SeekStart)
+		require.NoError(t, err)
+		buf := make([]byte, 10)
+		_, err = io.ReadFull(f, buf)
+		require.NoError(t, err)
+		requests := server.Requests()
+		_, err = f.Seek(4096, io.SeekCurrent)
+		require.NoError(t, err)
+		_, err = io.ReadFull(f, buf)
+		require.NoError(t, err)
+		assert.Equal(t, content[4116:4126], buf)
+		assert.Equal(t, requests, server.Requests())
+	})
+
+	t.Run("read at", func(t *testing.T) {
+		f, err := c.GetNodeTree().Open(n)
+		require.NoError(t, err)
+		defer f.Close()
+
+		buf := make([]byte, 512)
+		read, err := f.ReadAt(buf, 2<<20)
+		require.NoError(t, err)
+		assert.Equal(t, 512, read)
+		assert.Equal(t, content[2<<20:2<<20+512], buf)
+
+		read, err = f.ReadAt(buf, int64(len(content)-100))
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 100, read)
+		assert.Equal(t, content[len(content)-100:], buf[:100])
+
+		_, err = f.ReadAt(buf, int64(len(content)))
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("folders cannot be opened", func(t *testing.T) {
+		_, err := c.GetNodeTree().Open(c.GetNodeTree().Node)
+		assert.Error(t, err)
+	})
+}
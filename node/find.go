@@ -1,51 +1,58 @@
 package node
 
 import (
-	"regexp"
-	"strings"
+	"context"
 
 	"github.com/montaguethomas/acd-go/constants"
 	"github.com/montaguethomas/acd-go/log"
 )
 
-// FindNode finds a node for a particular path.
-// TODO(kalbasit): This does not perform well, this should be cached in a map
-// path->node and calculated on load (fresh, cache, refresh).
+// FindNode finds a node for a particular path. The path is case
+// insensitive, empty components are ignored.
 func (nt *Tree) FindNode(path string) (*Node, error) {
-	// replace multiple n*/ with /
-	re := regexp.MustCompile("/[/]*")
-	path = string(re.ReplaceAll([]byte(path), []byte("/")))
-	// chop off any leading or trailing slashes.
-	path = strings.Trim(path, "/")
-	// did we ask for the root node?
-	if path == "" {
-		return nt.Node, nil
+	node, err := nt.findNode(path)
+	if err != nil {
+		log.Errorf("%s: %s", err, path)
+		return nil, err
 	}
-	// lowercase path
-	path = strings.ToLower(path)
+	return node, nil
+}
 
-	// initialize our search from the root node
-	node := nt.Node
+// LookupNode is like FindNode but does not log when the node is not found, to
+// check whether a path exists.
+func (nt *Tree) LookupNode(path string) (*Node, error) {
+	return nt.findNode(path)
+}
 
-	// iterate over the path parts until we find the path (or not).
-	parts := strings.Split(path, "/")
-	for _, part := range parts {
-		var ok bool
-		node, ok = node.Nodes[part]
-		if !ok {
-			log.Errorf("%s: %s", constants.ErrNodeNotFound, path)
-			return nil, constants.ErrNodeNotFound
-		}
+// findNode is like FindNode but does not log when the node is not found. It
+// is safe to call while nodes are added to the tree.
+func (nt *Tree) findNode(path string) (*Node, error) {
+	if nt.lazy {
+		return nt.lazyFindNode(context.Background(), normalizePath(path))
+	}
+	node, ok := nt.lookupPath(normalizePath(path))
+	if !ok {
+		return nil, constants.ErrNodeNotFound
 	}
-
 	return node, nil
 }
 
 // FindById returns the node identified by the Id.
 func (nt *Tree) FindById(id string) (*Node, error) {
+	n, err := nt.LookupById(id)
+	if err != nil {
+		log.Errorf("%s: Id %q", err, id)
+		return nil, err
+	}
+	return n, nil
+}
+
+// LookupById is like FindById but does not log when the node is not found.
+func (nt *Tree) LookupById(id string) (*Node, error) {
+	nt.RLock()
 	n, ok := nt.nodeIdMap[id]
+	nt.RUnlock()
 	if !ok {
-		log.Errorf("%s: Id %q", constants.ErrNodeNotFound, id)
 		return nil, constants.ErrNodeNotFound
 	}
 	return n, nil
@@ -0,0 +1,219 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+// FS is an io/fs file system over a Tree. Names are slash-separated paths
+// relative to the root of the tree, which is ".", and are looked up case
+// insensitively like FindNode does. The content of the files is downloaded
+// when they are read.
+type FS struct {
+	ctx  context.Context
+	tree *Tree
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// FS returns the tree as an io/fs file system.
+func (nt *Tree) FS() *FS {
+	return nt.FSContext(context.Background())
+}
+
+// FSContext is like FS but uses ctx for all the requests.
+func (nt *Tree) FSContext(ctx context.Context) *FS {
+	return &FS{ctx: ctx, tree: nt}
+}
+
+// Open implements fs.FS.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	n, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.IsDir() {
+		if err := fsys.tree.LoadChildrenContext(fsys.ctx, n); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &fsDir{node: n, name: name}, nil
+	}
+	f, err := fsys.tree.OpenContext(fsys.ctx, n)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &fsFile{File: f, name: name}, nil
+}
+
+// Stat implements fs.StatFS.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(n, name), nil
+}
+
+// ReadDir implements fs.ReadDirFS. The entries are sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := fsys.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: constants.ErrPathIsNotFolder}
+	}
+	if err := fsys.tree.LoadChildrenContext(fsys.ctx, n); err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return readDirEntries(n), nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	n, err := fsys.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: constants.ErrPathIsFolder}
+	}
+	if n.Size() == 0 {
+		return []byte{}, nil
+	}
+	body, err := fsys.tree.DownloadContext(fsys.ctx, n)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return content, nil
+}
+
+// lookup returns the node of name, mapping constants.ErrNodeNotFound to
+// fs.ErrNotExist.
+func (fsys *FS) lookup(op, name string) (*Node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	p := name
+	if p == "." {
+		p = "/"
+	}
+	n, err := fsys.tree.FindNode(p)
+	if errors.Is(err, constants.ErrNodeNotFound) {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return n, nil
+}
+
+// readDirEntries returns the children of the node sorted by name.
+func readDirEntries(n *Node) []fs.DirEntry {
+	n.RLock()
+	entries := make([]fs.DirEntry, 0, len(n.Nodes))
+	for _, child := range n.Nodes {
+		entries = append(entries, fs.FileInfoToDirEntry(newFileInfo(child, child.Name)))
+	}
+	n.RUnlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// fileInfo implements fs.FileInfo for a node. Sys returns the *Node.
+type fileInfo struct {
+	node *Node
+	name string
+}
+
+// newFileInfo returns the fs.FileInfo of the node opened as name.
+func newFileInfo(n *Node, name string) fs.FileInfo {
+	if n.Name != "" && name != "." {
+		name = n.Name
+	}
+	return &fileInfo{node: n, name: name}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return int64(fi.node.Size()) }
+func (fi *fileInfo) ModTime() time.Time { return fi.node.ModTime() }
+func (fi *fileInfo) IsDir() bool        { return fi.node.IsDir() }
+func (fi *fileInfo) Sys() any           { return fi.node }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.node.IsDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// fsFile is a file opened by FS.Open.
+type fsFile struct {
+	*File
+	name string
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return newFileInfo(f.node, f.name), nil
+}
+
+// fsDir is a folder opened by FS.Open, it implements fs.ReadDirFile.
+type fsDir struct {
+	node    *Node
+	name    string
+	entries []fs.DirEntry
+	offset  int
+	closed  bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return newFileInfo(d.node, d.name), nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: constants.ErrPathIsFolder}
+}
+
+func (d *fsDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+	if d.entries == nil {
+		d.entries = readDirEntries(d.node)
+	}
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(remaining))
+	d.offset += count
+	return remaining[:count], nil
+}
+
+func (d *fsDir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
@@ -0,0 +1,67 @@
+package node_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FS(t *testing.T) {
+	c, server := newTestClient(t)
+	files := map[string]string{
+		"README.md":            "readme",
+		"docs/guide.txt":       "guide",
+		"docs/empty":           "",
+		"pictures/2024/a.jpg":  "jpeg",
+		"pictures/2024/b.jpg":  "another jpeg",
+		"pictures/notes/x.txt": "x",
+	}
+	for name, content := range files {
+		_, err := server.PutFile(name, []byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.GetNodeTree().Sync())
+	fsys := c.GetNodeTree().FS()
+
+	require.NoError(t, fstest.TestFS(fsys, "README.md", "docs/guide.txt", "docs/empty", "pictures/2024/a.jpg", "pictures/notes/x.txt"))
+
+	t.Run("file info", func(t *testing.T) {
+		fi, err := fs.Stat(fsys, "pictures/2024/b.jpg")
+		require.NoError(t, err)
+		assert.Equal(t, "b.jpg", fi.Name())
+		assert.Equal(t, int64(len("another jpeg")), fi.Size())
+		assert.Equal(t, fs.FileMode(0444), fi.Mode())
+		assert.False(t, fi.ModTime().IsZero())
+
+		fi, err = fs.Stat(fsys, "pictures")
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+		assert.Equal(t, fs.ModeDir|0555, fi.Mode())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := fsys.Open("docs/missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = fs.ReadFile(fsys, "missing/guide.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = fsys.Open("/docs")
+		assert.ErrorIs(t, err, fs.ErrInvalid)
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		var walked []string
+		err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			walked = append(walked, p)
+			return err
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			".", "README.md", "docs", "docs/empty", "docs/guide.txt",
+			"pictures", "pictures/2024", "pictures/2024/a.jpg", "pictures/2024/b.jpg",
+			"pictures/notes", "pictures/notes/x.txt",
+		}, walked)
+	})
+}
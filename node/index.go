@@ -0,0 +1,191 @@
+package node
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// pathIndex maps the lowercase paths of the nodes reachable from the root to
+// the nodes, and the nodes back to their paths. A node with several parents
+// has several paths, the first one being its path through its first parent
+// indexed. The index is updated by Tree.attach and Tree.detach as the nodes
+// are added to and removed from their parents.
+type pathIndex struct {
+	mutex sync.RWMutex
+	nodes map[string]*Node
+	paths map[*Node][]string
+}
+
+// newPathIndex returns the index of the nodes under root.
+func newPathIndex(root *Node) *pathIndex {
+	idx := &pathIndex{
+		nodes: make(map[string]*Node),
+		paths: make(map[*Node][]string),
+	}
+	if root != nil {
+		idx.add("/", root)
+	}
+	return idx
+}
+
+// add indexes n, and its descendants, at p. The mutex must be held.
+func (idx *pathIndex) add(p string, n *Node) {
+	key := strings.ToLower(p)
+	if idx.nodes[key] == n {
+		// the descendants are indexed with n.
+		return
+	}
+	for _, existing := range idx.paths[n] {
+		if strings.HasPrefix(key, strings.ToLower(existing)+"/") {
+			log.Errorf("node %s is its own ancestor at %s", n.Id, p)
+			return
+		}
+	}
+	idx.remove(p)
+	idx.nodes[key] = n
+	idx.paths[n] = append(idx.paths[n], p)
+	for _, child := range n.children() {
+		if child.Name != "" {
+			idx.add(path.Join(p, child.Name), child)
+		}
+	}
+}
+
+// remove removes the node at p, and its descendants, from the index. The
+// mutex must be held.
+func (idx *pathIndex) remove(p string) {
+	key := strings.ToLower(p)
+	n, ok := idx.nodes[key]
+	if !ok {
+		return
+	}
+	delete(idx.nodes, key)
+	paths := slices.DeleteFunc(idx.paths[n], func(indexed string) bool {
+		return strings.ToLower(indexed) == key
+	})
+	if len(paths) == 0 {
+		delete(idx.paths, n)
+	} else {
+		idx.paths[n] = paths
+	}
+	for _, child := range n.children() {
+		if child.Name != "" {
+			idx.remove(path.Join(p, child.Name))
+		}
+	}
+}
+
+// children returns the children of the node.
+func (n *Node) children() []*Node {
+	n.RLock()
+	defer n.RUnlock()
+	children := make([]*Node, 0, len(n.Nodes))
+	for _, child := range n.Nodes {
+		children = append(children, child)
+	}
+	return children
+}
+
+// pathIndex returns the index of the tree, building it on first use.
+func (nt *Tree) pathIndex() *pathIndex {
+	nt.RLock()
+	idx, root := nt.index, nt.Node
+	nt.RUnlock()
+	if idx != nil {
+		return idx
+	}
+
+	idx = newPathIndex(root)
+	nt.Lock()
+	defer nt.Unlock()
+	if nt.index == nil {
+		nt.index = idx
+	}
+	return nt.index
+}
+
+// resetIndex rebuilds the index from the root node, after it was replaced.
+func (nt *Tree) resetIndex() {
+	nt.RLock()
+	root := nt.Node
+	nt.RUnlock()
+	idx := newPathIndex(root)
+	nt.Lock()
+	nt.index = idx
+	nt.Unlock()
+}
+
+// attach adds child to the children of parent and indexes it under the paths
+// of parent.
+func (nt *Tree) attach(parent, child *Node) {
+	parent.addChild(child)
+	idx := nt.pathIndex()
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	if child.Name == "" {
+		return
+	}
+	for _, parentPath := range slices.Clone(idx.paths[parent]) {
+		idx.add(path.Join(parentPath, child.Name), child)
+	}
+}
+
+// detach removes child from the children of parent and from the index. Like
+// removeChild, it removes the child of parent with the name of child.
+func (nt *Tree) detach(parent, child *Node) {
+	idx := nt.pathIndex()
+	idx.mutex.Lock()
+	if child.Name != "" {
+		for _, parentPath := range slices.Clone(idx.paths[parent]) {
+			idx.remove(path.Join(parentPath, child.Name))
+		}
+	}
+	idx.mutex.Unlock()
+	parent.removeChild(child)
+}
+
+// PathOf returns the path of the node, through its first parent if it has
+// several. It returns constants.ErrNodeNotFound if the node cannot be reached
+// from the root of the tree.
+func (nt *Tree) PathOf(n *Node) (string, error) {
+	idx := nt.pathIndex()
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	paths, ok := idx.paths[n]
+	if !ok {
+		return "", constants.ErrNodeNotFound
+	}
+	return paths[0], nil
+}
+
+// lookupPath returns the node at the normalized, lowercase, path p.
+func (nt *Tree) lookupPath(p string) (*Node, bool) {
+	idx := nt.pathIndex()
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	n, ok := idx.nodes[p]
+	return n, ok
+}
+
+// normalizePath returns p lowercased, with a leading slash and without empty
+// or trailing components.
+func normalizePath(p string) string {
+	var b strings.Builder
+	b.Grow(len(p) + 1)
+	for _, part := range strings.Split(p, "/") {
+		if part == "" {
+			continue
+		}
+		b.WriteByte('/')
+		b.WriteString(part)
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return strings.ToLower(b.String())
+}
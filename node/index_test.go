@@ -0,0 +1,150 @@
+package node
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPathIndex(t *testing.T) {
+	root := &Node{Id: "root", IsRoot: true, Kind: KindFolder}
+	nt := &Tree{Node: root, nodeIdMap: map[string]*Node{"root": root}}
+	newChild := func(parent *Node, name string, kind NodeKind) *Node {
+		n := &Node{Id: name, Name: name, Kind: kind, Parents: []string{parent.Id}}
+		nt.nodeIdMap[n.Id] = n
+		nt.attach(parent, n)
+		return n
+	}
+	photos := newChild(root, "Photos", KindFolder)
+	album := newChild(photos, "Album", KindFolder)
+	img := newChild(album, "IMG.jpg", KindFile)
+	archive := newChild(root, "archive", KindFolder)
+
+	for p, want := range map[string]*Node{
+		"/":                       root,
+		"photos":                  photos,
+		"//PHOTOS//album/":        album,
+		"/photos/album/img.JPG":   img,
+		"/archive":                archive,
+		"/photos/album/IMG.jpg/x": nil,
+	} {
+		got, err := nt.findNode(p)
+		if want == nil {
+			if err == nil {
+				t.Errorf("findNode(%q): want an error got %s", p, got.Id)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("findNode(%q): want %s got %v (%v)", p, want.Id, got, err)
+		}
+	}
+	if p, err := nt.PathOf(img); err != nil || p != "/Photos/Album/IMG.jpg" {
+		t.Errorf("PathOf(img): want /Photos/Album/IMG.jpg got %q (%v)", p, err)
+	}
+
+	// moving a folder moves its descendants.
+	nt.detach(photos, album)
+	album.Parents = []string{archive.Id}
+	nt.attach(archive, album)
+	if _, err := nt.findNode("/photos/album/img.jpg"); err == nil {
+		t.Errorf("findNode() the old path of a moved file: want an error got nil")
+	}
+	if got, err := nt.findNode("/archive/album/img.jpg"); err != nil || got != img {
+		t.Errorf("findNode() the new path of a moved file: want img got %v (%v)", got, err)
+	}
+	if p, err := nt.PathOf(img); err != nil || p != "/archive/Album/IMG.jpg" {
+		t.Errorf("PathOf(img) after the move: want /archive/Album/IMG.jpg got %q (%v)", p, err)
+	}
+
+	// a node with two parents has two paths, the first one is its path.
+	album.Parents = append(album.Parents, photos.Id)
+	nt.attach(photos, album)
+	if got, err := nt.findNode("/photos/album/img.jpg"); err != nil || got != img {
+		t.Errorf("findNode() the second path: want img got %v (%v)", got, err)
+	}
+	nt.detach(archive, album)
+	if p, err := nt.PathOf(img); err != nil || p != "/Photos/Album/IMG.jpg" {
+		t.Errorf("PathOf(img) after removing the first parent: want /Photos/Album/IMG.jpg got %q (%v)", p, err)
+	}
+
+	nt.detach(photos, album)
+	if _, err := nt.PathOf(img); err == nil {
+		t.Errorf("PathOf() a removed node: want an error got nil")
+	}
+}
+
+// newBenchmarkTree returns a tree of folders nodes, each with files files,
+// and the paths of the files.
+func newBenchmarkTree(folders, files int) (*Tree, []string) {
+	root := &Node{Id: "root", IsRoot: true, Kind: KindFolder, Nodes: make(Nodes, folders)}
+	nt := &Tree{Node: root, nodeIdMap: map[string]*Node{"root": root}}
+	paths := make([]string, 0, folders*files)
+	for i := 0; i < folders; i++ {
+		name := fmt.Sprintf("Folder%d", i)
+		folder := &Node{Id: name, Name: name, Kind: KindFolder, Parents: []string{root.Id}, Nodes: make(Nodes, files)}
+		root.Nodes[strings.ToLower(name)] = folder
+		for j := 0; j < files; j++ {
+			fileName := fmt.Sprintf("File%d.txt", j)
+			folder.Nodes[strings.ToLower(fileName)] = &Node{Id: name + fileName, Name: fileName, Kind: KindFile, Parents: []string{folder.Id}}
+			paths = append(paths, "/"+name+"/"+fileName)
+		}
+	}
+	return nt, paths
+}
+
+// walkFindNode is FindNode before the path index, kept to compare them.
+func walkFindNode(nt *Tree, path string) *Node {
+	re := regexp.MustCompile("/[/]*")
+	path = string(re.ReplaceAll([]byte(path), []byte("/")))
+	path = strings.ToLower(strings.Trim(path, "/"))
+	node := nt.Node
+	for _, part := range strings.Split(path, "/") {
+		node.RLock()
+		next, ok := node.Nodes[part]
+		node.RUnlock()
+		if !ok {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+func benchmarkFindNode(b *testing.B, find func(*Tree, string) *Node) {
+	// a million files in a thousand folders.
+	nt, paths := newBenchmarkTree(1000, 1000)
+	nt.pathIndex()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if find(nt, paths[i%len(paths)]) == nil {
+			b.Fatalf("%s not found", paths[i%len(paths)])
+		}
+	}
+}
+
+func BenchmarkFindNode(b *testing.B) {
+	benchmarkFindNode(b, func(nt *Tree, p string) *Node {
+		n, _ := nt.findNode(p)
+		return n
+	})
+}
+
+func BenchmarkFindNodeWalk(b *testing.B) {
+	benchmarkFindNode(b, walkFindNode)
+}
+
+func BenchmarkPathOf(b *testing.B) {
+	nt, paths := newBenchmarkTree(1000, 1000)
+	nodes := make([]*Node, len(paths))
+	for i, p := range paths {
+		nodes[i], _ = nt.findNode(p)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := nt.PathOf(nodes[i%len(nodes)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
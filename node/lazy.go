@@ -0,0 +1,279 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// NewLazyTree returns a tree which only holds the root folder when it is
+// created. The children of a folder are listed the first time they are used,
+// by FindNode, Walk, Glob, the FS or LoadChildren, and listed again once ttl
+// has elapsed, never if ttl is 0. Every syncInterval the changes of the
+// account are polled to list again the folders whose children have changed
+// and to remove the trashed nodes, without keeping the nodes which are not in
+// the tree. The tree is not saved to a cache file and Sync does not emit the
+// events of the nodes it has not loaded.
+//
+// The changes API has no request for the current checkpoint alone, so the
+// first sync, started in the background, streams the whole history of the
+// changes once, discarding the nodes. Until it is done the listings only
+// expire on ttl, then the folders listed so far are listed again.
+func NewLazyTree(c client, chunkSize int, syncInterval, ttl time.Duration) (*Tree, error) {
+	nt := &Tree{
+		client:    c,
+		chunkSize: chunkSize,
+		nodeIdMap: make(map[string]*Node),
+		lazy:      true,
+		lazyTTL:   ttl,
+		loadedAt:  make(map[string]time.Time),
+		folderMu:  make(map[string]*sync.Mutex),
+		now:       time.Now,
+	}
+
+	root, err := nt.fetchRoot(context.Background())
+	if err != nil {
+		log.Errorf("fetching the root failed %s", err)
+		return nil, err
+	}
+	nt.Node = root
+	nt.nodeIdMap[root.Id] = root
+	nt.resetIndex()
+
+	nt.startSync(syncInterval)
+	return nt, nil
+}
+
+// LoadChildren lists the children of the folder if they have not been listed
+// yet, or have expired, in a lazy tree. It does nothing for a file or in a
+// tree synced with NewTree.
+func (nt *Tree) LoadChildren(n *Node) error {
+	return nt.LoadChildrenContext(context.Background(), n)
+}
+
+// LoadChildrenContext is like LoadChildren but uses ctx for the requests.
+func (nt *Tree) LoadChildrenContext(ctx context.Context, n *Node) error {
+	if !nt.lazy || !n.IsDir() {
+		return nil
+	}
+	if nt.isLoaded(n.Id) {
+		return nil
+	}
+
+	// only the listings of the same folder wait for each other, the one
+	// which waited finds the folder loaded.
+	nt.loadMu.Lock()
+	mu, ok := nt.folderMu[n.Id]
+	if !ok {
+		mu = &sync.Mutex{}
+		nt.folderMu[n.Id] = mu
+	}
+	nt.loadMu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	if nt.isLoaded(n.Id) {
+		return nil
+	}
+
+	nt.loadMu.Lock()
+	listedAt, invalidations := nt.now(), nt.invalidations
+	nt.loadMu.Unlock()
+	children, err := nt.listChildren(ctx, n.Id)
+	if err != nil {
+		return err
+	}
+	nt.setChildren(n, children)
+
+	// the listing may predate the changes applied while it was requested,
+	// the folder is listed again the next time it is used.
+	nt.loadMu.Lock()
+	if nt.invalidations == invalidations {
+		nt.loadedAt[n.Id] = listedAt
+	}
+	nt.loadMu.Unlock()
+	return nil
+}
+
+// isLoaded returns whether the children of the folder identified by id have
+// been listed and have not expired.
+func (nt *Tree) isLoaded(id string) bool {
+	nt.loadMu.Lock()
+	defer nt.loadMu.Unlock()
+	loadedAt, ok := nt.loadedAt[id]
+	return ok && (nt.lazyTTL <= 0 || nt.now().Sub(loadedAt) < nt.lazyTTL)
+}
+
+// setChildren replaces the children of parent by the listed ones, updating
+// the nodes which are already in the tree.
+func (nt *Tree) setChildren(parent *Node, children []*Node) {
+	listed := make(map[string]bool, len(children))
+	for _, child := range children {
+		if child.Status != "" && child.Status != StatusAvailable {
+			continue
+		}
+		listed[child.Id] = true
+		nt.RLock()
+		existing, ok := nt.nodeIdMap[child.Id]
+		nt.RUnlock()
+		if ok {
+			nt.removeNodeFromTree(existing)
+			if err := existing.update(child); err != nil {
+				continue
+			}
+			child = existing
+		}
+		nt.addNodeToNodeIdMap(child)
+
+		child.RLock()
+		parentIds := slices.Clone(child.Parents)
+		child.RUnlock()
+		for _, parentId := range parentIds {
+			nt.RLock()
+			p, ok := nt.nodeIdMap[parentId]
+			nt.RUnlock()
+			if ok {
+				nt.attach(p, child)
+			}
+		}
+	}
+
+	for _, child := range parent.children() {
+		if !listed[child.Id] {
+			nt.removeNodeFromTree(child)
+		}
+	}
+}
+
+// fetchCheckpoint streams the changes of a lazy tree for their checkpoint
+// alone. The folders listed before may have missed changes the next polls do
+// not report, their listings are dropped.
+func (nt *Tree) fetchCheckpoint(ctx context.Context) error {
+	discard := func([]*Node) error { return nil }
+	if err := nt.fetchChanges(ctx, discard); err != nil {
+		return err
+	}
+	nt.loadMu.Lock()
+	nt.checkpointed = true
+	nt.invalidations++
+	clear(nt.loadedAt)
+	nt.loadMu.Unlock()
+	return nil
+}
+
+// invalidateNodes applies a chunk of changes to a lazy tree: the parents of
+// the changed nodes, before and after the change, are listed again when they
+// are used and the nodes which are no longer available are removed.
+func (nt *Tree) invalidateNodes(crNodes []*Node) error {
+	var events []Event
+	nt.loadMu.Lock()
+	nt.invalidations++
+	for _, crNode := range crNodes {
+		if crNode.IsRoot {
+			continue
+		}
+		for _, parentId := range crNode.Parents {
+			delete(nt.loadedAt, parentId)
+		}
+
+		nt.RLock()
+		existing, ok := nt.nodeIdMap[crNode.Id]
+		nt.RUnlock()
+		if !ok {
+			continue
+		}
+		existing.RLock()
+		for _, parentId := range existing.Parents {
+			delete(nt.loadedAt, parentId)
+		}
+		existing.RUnlock()
+
+		if !crNode.IsAvailable() {
+			log.Tracef("node Id %s name %s has been deleted", crNode.Id, crNode.Name)
+			old, oldPath := nt.before(existing)
+			nt.removeNodeFromTree(existing)
+			delete(nt.loadedAt, crNode.Id)
+			if old != nil {
+				events = append(events, removedEvent(old, crNode, oldPath))
+			}
+		}
+	}
+	nt.loadMu.Unlock()
+
+	// the subscribers may use the tree.
+	nt.emit(events...)
+	return nil
+}
+
+// lazyFindNode finds the node at the normalized path p, listing the folders
+// on the way as needed.
+func (nt *Tree) lazyFindNode(ctx context.Context, p string) (*Node, error) {
+	nt.RLock()
+	n := nt.Node
+	nt.RUnlock()
+	if p == "/" {
+		return n, nil
+	}
+	parts := strings.Split(p[1:], "/")
+	for i := range parts {
+		if err := nt.LoadChildrenContext(ctx, n); err != nil {
+			return nil, err
+		}
+		child, ok := nt.lookupPath("/" + strings.Join(parts[:i+1], "/"))
+		if !ok {
+			return nil, constants.ErrNodeNotFound
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// childrenOf returns the children of the node, listing them first in a lazy
+// tree. The children already in the tree are returned with the error if the
+// listing fails.
+func (nt *Tree) childrenOf(n *Node) ([]*Node, error) {
+	err := nt.LoadChildren(n)
+	return n.children(), err
+}
+
+// fetchRoot returns the root folder of the account.
+func (nt *Tree) fetchRoot(ctx context.Context) (*Node, error) {
+	v := url.Values{}
+	v.Set("filters", "isRoot:true")
+	nodes, err := nt.listNodes(ctx, "nodes", v)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		if n.IsRoot {
+			return n, nil
+		}
+	}
+	log.Errorf("%s: root", constants.ErrNodeNotFound)
+	return nil, constants.ErrNodeNotFound
+}
+
+// listChildren returns the children of the folder identified by id.
+func (nt *Tree) listChildren(ctx context.Context, id string) ([]*Node, error) {
+	return nt.listNodes(ctx, fmt.Sprintf("nodes/%s/children", id), url.Values{})
+}
+
+// listNodes returns all the nodes of the metadata endpoint at path with the
+// query v.
+func (nt *Tree) listNodes(ctx context.Context, path string, v url.Values) ([]*Node, error) {
+	var nodes []*Node
+	err := ListNodes(ctx, nt.client, path, v, func(page []*Node) error {
+		nodes = append(nodes, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
@@ -0,0 +1,209 @@
+package node_test
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/montaguethomas/acd-go/acdtest"
+	"github.com/montaguethomas/acd-go/client"
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changesTransport counts the requests for the changes and blocks them until
+// release is closed, if it is not nil.
+type changesTransport struct {
+	release   chan struct{}
+	requests  atomic.Int32
+	completed atomic.Int32
+}
+
+func (ct *changesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/changes") {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	ct.requests.Add(1)
+	if ct.release != nil {
+		select {
+		case <-ct.release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	res, err := http.DefaultTransport.RoundTrip(req)
+	ct.completed.Add(1)
+	return res, err
+}
+
+// lazyTestConfig returns the config of a client with a lazy tree for the fake
+// server.
+func lazyTestConfig(server *acdtest.Server, ttl string, transport http.RoundTripper) *client.Config {
+	return &client.Config{
+		EndpointURL:  server.EndpointURL(),
+		LazyTree:     true,
+		LazyTreeTTL:  ttl,
+		RefreshToken: server.RefreshToken,
+		SyncInterval: "1h",
+		TokenURL:     server.TokenURL(),
+		Transport:    transport,
+	}
+}
+
+// newLazyTestClient returns a client with a lazy tree for the fake server,
+// once the background sync has got the checkpoint of the changes.
+func newLazyTestClient(t *testing.T, server *acdtest.Server, ttl string) *client.Client {
+	t.Helper()
+	transport := &changesTransport{}
+	c, err := client.New(lazyTestConfig(server, ttl, transport))
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	// Sync waits for the background sync, which is the first to request the
+	// changes.
+	require.Eventually(t, func() bool { return transport.requests.Load() > 0 }, time.Second, time.Millisecond)
+	require.NoError(t, c.GetNodeTree().Sync())
+	return c
+}
+
+func Test_LazyTree(t *testing.T) {
+	server := acdtest.NewServer()
+	t.Cleanup(server.Close)
+	for _, name := range []string{"/photos/2024/a.jpg", "/photos/2024/b.jpg", "/docs/notes.txt"} {
+		_, err := server.PutFile(name, []byte(name))
+		require.NoError(t, err)
+	}
+	notes, ok := server.Lookup("/docs/notes.txt")
+	require.True(t, ok)
+
+	t.Run("the checkpoint is fetched in the background", func(t *testing.T) {
+		transport := &changesTransport{release: make(chan struct{})}
+		c, err := client.New(lazyTestConfig(server, "", transport))
+		require.NoError(t, err)
+		defer c.Close()
+		defer close(transport.release)
+		assert.Zero(t, transport.completed.Load(), "New does not wait for the changes")
+
+		_, err = c.GetNodeTree().FindNode("/docs/notes.txt")
+		assert.NoError(t, err, "the folders are listed before the checkpoint")
+	})
+
+	t.Run("folders are listed on demand", func(t *testing.T) {
+		c := newLazyTestClient(t, server, "")
+		nt := c.GetNodeTree()
+
+		requests := server.Requests()
+		n, err := nt.FindNode("/Photos/2024/a.jpg")
+		require.NoError(t, err)
+		assert.Equal(t, "a.jpg", n.Name)
+		assert.Equal(t, 3, server.Requests()-requests, "the root, /photos and /photos/2024 are listed")
+		_, err = nt.FindById(notes.Id)
+		assert.Equal(t, constants.ErrNodeNotFound, err, "/docs is not listed")
+
+		requests = server.Requests()
+		_, err = nt.FindNode("/photos/2024/b.jpg")
+		require.NoError(t, err)
+		assert.Equal(t, requests, server.Requests(), "the listings are cached")
+
+		var paths []string
+		require.NoError(t, nt.Walk("/", func(p string, n *node.Node, err error) error {
+			paths = append(paths, p)
+			return err
+		}))
+		assert.Equal(t, []string{"/", "/docs", "/docs/notes.txt", "/photos", "/photos/2024", "/photos/2024/a.jpg", "/photos/2024/b.jpg"}, paths)
+
+		_, err = nt.FindNode("/photos/2024/missing.jpg")
+		assert.Equal(t, constants.ErrNodeNotFound, err)
+	})
+
+	t.Run("listing errors are returned", func(t *testing.T) {
+		c := newLazyTestClient(t, server, "")
+		nt := c.GetNodeTree()
+
+		server.FailNext(http.StatusForbidden, 1)
+		var walkErrs []string
+		err := nt.Walk("/", func(p string, n *node.Node, err error) error {
+			if err != nil {
+				walkErrs = append(walkErrs, p)
+				return fs.SkipDir
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/"}, walkErrs, "the listing of the root failed")
+
+		server.FailNext(http.StatusForbidden, 1)
+		_, err = nt.Glob("/docs/*.txt")
+		assert.Error(t, err)
+		_, err = nt.FindNode("/photos")
+		require.NoError(t, err)
+		server.FailNext(http.StatusForbidden, 1)
+		_, err = nt.Query(&node.Query{Root: "/photos"})
+		assert.Error(t, err, "the listing of /photos failed")
+
+		nodes, err := nt.Glob("/docs/*.txt")
+		require.NoError(t, err)
+		assert.Len(t, nodes, 1)
+	})
+
+	t.Run("concurrent listings", func(t *testing.T) {
+		c := newLazyTestClient(t, server, "")
+		nt := c.GetNodeTree()
+
+		requests := server.Requests()
+		var wg sync.WaitGroup
+		for _, name := range []string{"/photos/2024/a.jpg", "/photos/2024/b.jpg", "/docs/notes.txt", "/docs/notes.txt"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := nt.FindNode(name)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 4, server.Requests()-requests, "every folder is listed once")
+	})
+
+	t.Run("changes invalidate the listings", func(t *testing.T) {
+		c := newLazyTestClient(t, server, "")
+		nt := c.GetNodeTree()
+		assert.NotEmpty(t, nt.Checkpoint, "the tree starts from the current checkpoint")
+		_, err := nt.FindNode("/docs/notes.txt")
+		require.NoError(t, err)
+
+		var events []node.Event
+		unsubscribe := nt.Subscribe(func(e node.Event) { events = append(events, e) })
+		defer unsubscribe()
+		_, err = server.PutFile("/docs/todo.txt", []byte("todo"))
+		require.NoError(t, err)
+		require.NoError(t, server.Trash("/docs/notes.txt"))
+		_, err = nt.FindNode("/docs/todo.txt")
+		assert.Equal(t, constants.ErrNodeNotFound, err, "the listing of /docs has not expired")
+
+		require.NoError(t, nt.Sync())
+		_, err = nt.FindNode("/docs/todo.txt")
+		assert.NoError(t, err)
+		_, err = nt.FindNode("/docs/notes.txt")
+		assert.Equal(t, constants.ErrNodeNotFound, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, node.EventTrashed, events[0].Type)
+		assert.Equal(t, "/docs/notes.txt", events[0].OldPath)
+	})
+
+	t.Run("listings expire", func(t *testing.T) {
+		c := newLazyTestClient(t, server, "1ns")
+		nt := c.GetNodeTree()
+		_, err := nt.FindNode("/photos/2024/a.jpg")
+		require.NoError(t, err)
+
+		_, err = server.PutFile("/photos/2024/c.jpg", []byte("c"))
+		require.NoError(t, err)
+		_, err = nt.FindNode("/photos/2024/c.jpg")
+		assert.NoError(t, err)
+	})
+}
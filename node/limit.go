@@ -0,0 +1,48 @@
+package node
+
+import (
+	"context"
+	"io"
+)
+
+// Limiter limits the rate at which the content of the nodes is transferred.
+// It is shared by all the transfers of a direction and must be safe for
+// concurrent use.
+type Limiter interface {
+	// WaitN blocks until n bytes may be transferred or ctx is done.
+	WaitN(ctx context.Context, n int) error
+}
+
+// limitedReadSize caps the size of a read from a limitedReader so the
+// transfers sharing a Limiter make progress in turns.
+const limitedReadSize = 32 << 10
+
+// limitedReader waits for the Limiter to allow the bytes read from r before
+// returning them.
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter Limiter
+}
+
+// newLimitedReader returns r limited by limiter, or r itself if limiter is
+// nil.
+func newLimitedReader(ctx context.Context, r io.Reader, limiter Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: limiter}
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > limitedReadSize {
+		p = p[:limitedReadSize]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if err := lr.limiter.WaitN(lr.ctx, n); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
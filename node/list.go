@@ -0,0 +1,79 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// apiListNodesResponse is the response body for listing the nodes, the
+// children of a folder, the trash or a search, a page at a time.
+type apiListNodesResponse struct {
+	Count     uint64  `json:"count,omitempty"`
+	NextToken string  `json:"nextToken,omitempty"`
+	Nodes     []*Node `json:"data,omitempty"`
+}
+
+// ListNodes requests the nodes of the metadata endpoint at path with the
+// query v, a page of up to 200 nodes at a time, following the nextToken of
+// the responses. It calls fn with every page; an error returned by fn stops
+// the listing and is returned.
+func ListNodes(ctx context.Context, c client, path string, v url.Values, fn func([]*Node) error) error {
+	var nextToken string
+	for {
+		urlStr := c.GetMetadataURL(path)
+		u, err := url.Parse(urlStr)
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrParsingURL, urlStr)
+			return constants.ErrParsingURL
+		}
+
+		query := url.Values{}
+		for key, values := range v {
+			query[key] = values
+		}
+		query.Set("limit", "200")
+		if nextToken != "" {
+			query.Set("startToken", nextToken)
+		}
+		u.RawQuery = query.Encode()
+
+		// Make Request
+		req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+			return constants.ErrCreatingHTTPRequest
+		}
+		req.Header.Set("Content-Type", "application/json")
+		res, err := c.Do(req)
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+			return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+		}
+		if err := c.CheckResponse(res); err != nil {
+			return err
+		}
+
+		// Handle Response
+		response := apiListNodesResponse{}
+		err = json.NewDecoder(res.Body).Decode(&response)
+		res.Body.Close()
+		if err != nil {
+			log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
+			return constants.ErrJSONDecodingResponseBody
+		}
+
+		if err := fn(response.Nodes); err != nil {
+			return err
+		}
+		nextToken = response.NextToken
+		if nextToken == "" {
+			return nil
+		}
+	}
+}
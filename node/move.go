@@ -0,0 +1,176 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// Rename renames the node in all its parents. It fails with
+// constants.ErrFileExists if one of the parents already has a node named
+// newName.
+func (nt *Tree) Rename(n *Node, newName string) error {
+	return nt.RenameContext(context.Background(), n, newName)
+}
+
+// RenameContext is like Rename but uses ctx for the request.
+func (nt *Tree) RenameContext(ctx context.Context, n *Node, newName string) error {
+	if newName == "" || strings.Contains(newName, "/") {
+		log.Errorf("%s: %q", constants.ErrInvalidNodeName, newName)
+		return constants.ErrInvalidNodeName
+	}
+	if n.IsRoot {
+		log.Errorf("%s: cannot rename the root node", constants.ErrCannotMoveRootNode)
+		return constants.ErrCannotMoveRootNode
+	}
+	n.RLock()
+	name, parents := n.Name, slices.Clone(n.Parents)
+	n.RUnlock()
+	if newName == name {
+		return nil
+	}
+	for _, parentId := range parents {
+		if nt.hasOtherChild(parentId, newName, n) {
+			log.Errorf("%s: %s", constants.ErrFileExists, newName)
+			return constants.ErrFileExists
+		}
+	}
+
+	metadataJSON, err := json.Marshal(&patchNode{Name: newName})
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONEncoding, err)
+		return constants.ErrJSONEncoding
+	}
+	patchURL := nt.client.GetMetadataURL(fmt.Sprintf("nodes/%s", n.Id))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", patchURL, bytes.NewBuffer(metadataJSON))
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return constants.ErrCreatingHTTPRequest
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return nt.doNodeRequest(req, n)
+}
+
+// Move moves the node under newParent, removing it from all its other
+// parents. It fails with constants.ErrFileExists if newParent already has a
+// node with the same name.
+func (nt *Tree) Move(n *Node, newParent *Node) error {
+	return nt.MoveContext(context.Background(), n, newParent)
+}
+
+// MoveContext is like Move but uses ctx for the requests.
+func (nt *Tree) MoveContext(ctx context.Context, n *Node, newParent *Node) error {
+	if n.IsRoot {
+		log.Errorf("%s: cannot move the root node", constants.ErrCannotMoveRootNode)
+		return constants.ErrCannotMoveRootNode
+	}
+	if !newParent.IsDir() {
+		log.Errorf("%s: %s", constants.ErrPathIsNotFolder, newParent.Name)
+		return constants.ErrPathIsNotFolder
+	}
+	if nt.isAncestor(n, newParent) {
+		log.Errorf("%s: %s under %s", constants.ErrMoveIntoDescendant, n.Name, newParent.Name)
+		return constants.ErrMoveIntoDescendant
+	}
+	n.RLock()
+	name, parents := n.Name, slices.Clone(n.Parents)
+	n.RUnlock()
+
+	// add the new parent first, the node must always have a parent.
+	if !slices.Contains(parents, newParent.Id) {
+		if nt.hasOtherChild(newParent.Id, name, n) {
+			log.Errorf("%s: %s", constants.ErrFileExists, name)
+			return constants.ErrFileExists
+		}
+		if err := nt.childrenRequest(ctx, "PUT", newParent.Id, n); err != nil {
+			return err
+		}
+	}
+	for _, parentId := range parents {
+		if parentId == newParent.Id {
+			continue
+		}
+		if err := nt.childrenRequest(ctx, "DELETE", parentId, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// childrenRequest adds (PUT) or removes (DELETE) the parent of the node.
+func (nt *Tree) childrenRequest(ctx context.Context, method, parentId string, n *Node) error {
+	childrenURL := nt.client.GetMetadataURL(fmt.Sprintf("nodes/%s/children/%s", parentId, n.Id))
+	req, err := http.NewRequestWithContext(ctx, method, childrenURL, nil)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return constants.ErrCreatingHTTPRequest
+	}
+
+	return nt.doNodeRequest(req, n)
+}
+
+// doNodeRequest does the request which responds with the updated node and
+// moves the node in the tree according to its new name and parents.
+func (nt *Tree) doNodeRequest(req *http.Request, n *Node) error {
+	res, err := nt.client.Do(req)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+		return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	if err := nt.client.CheckResponse(res); err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	var newNode *Node
+	if err := json.NewDecoder(res.Body).Decode(&newNode); err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
+		return constants.ErrJSONDecodingResponseBody
+	}
+
+	_, err = nt.AddNode(newNode)
+	return err
+}
+
+// hasOtherChild returns whether the parent has a child named name other than
+// the node n.
+func (nt *Tree) hasOtherChild(parentId, name string, n *Node) bool {
+	nt.RLock()
+	parent, ok := nt.nodeIdMap[parentId]
+	nt.RUnlock()
+	if !ok {
+		return false
+	}
+	parent.RLock()
+	defer parent.RUnlock()
+	child, ok := parent.Nodes[strings.ToLower(name)]
+	return ok && child != n
+}
+
+// isAncestor returns whether n is descendant or one of its ancestors.
+func (nt *Tree) isAncestor(n, descendant *Node) bool {
+	if n == descendant || n.Id == descendant.Id {
+		return true
+	}
+	descendant.RLock()
+	parents := slices.Clone(descendant.Parents)
+	descendant.RUnlock()
+	for _, parentId := range parents {
+		nt.RLock()
+		parent, ok := nt.nodeIdMap[parentId]
+		nt.RUnlock()
+		if ok && nt.isAncestor(n, parent) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+type (
+	// Progress is the state of the transfer of the content of a node.
+	Progress struct {
+		// Name is the name of the node being transferred.
+		Name string
+		// Bytes is the number of bytes transferred so far.
+		Bytes int64
+		// Total is the number of bytes to transfer, -1 if it is unknown.
+		Total int64
+		// Rate is the average transfer rate in bytes per second.
+		Rate float64
+	}
+
+	// ProgressFunc receives the progress of a transfer. It is called by the
+	// goroutine reading the content, after every read, and should return
+	// quickly.
+	ProgressFunc func(Progress)
+
+	progressKey struct{}
+)
+
+// WithProgress returns a copy of ctx which makes the transfers using it
+// report their progress to fn. The transfers reporting their progress are
+// UploadContext, OverwriteContext, DownloadContext and DownloadRangeContext;
+// the progress of a range is relative to the start of the range.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFunc returns the ProgressFunc of ctx, or nil.
+func progressFunc(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressReader reports the bytes read from r to fn.
+type progressReader struct {
+	r        io.Reader
+	fn       ProgressFunc
+	start    time.Time
+	progress Progress
+	done     bool
+}
+
+// newProgressReader returns r reporting its progress to the ProgressFunc of
+// ctx, or r itself if ctx has none.
+func newProgressReader(ctx context.Context, r io.Reader, name string, total int64) io.Reader {
+	fn := progressFunc(ctx)
+	if fn == nil {
+		return r
+	}
+	return &progressReader{
+		r:        r,
+		fn:       fn,
+		start:    time.Now(),
+		progress: Progress{Name: name, Total: total},
+	}
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.progress.Bytes += int64(n)
+	if n > 0 || (err == io.EOF && !pr.done) {
+		pr.done = err == io.EOF
+		if elapsed := time.Since(pr.start).Seconds(); elapsed > 0 {
+			pr.progress.Rate = float64(pr.progress.Bytes) / elapsed
+		}
+		pr.fn(pr.progress)
+	}
+	return n, err
+}
+
+// readerSize returns the number of bytes left to read from r, or -1 if it
+// cannot be known without reading r.
+func readerSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
@@ -0,0 +1,71 @@
+package node_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Progress(t *testing.T) {
+	c, _ := newTestClient(t)
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	var reports []node.Progress
+	ctx := node.WithProgress(context.Background(), func(p node.Progress) {
+		reports = append(reports, p)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		reports = nil
+		_, err := c.UploadContext(ctx, "/progress.bin", false, nil, node.NewProperty(), bytes.NewReader(content))
+		require.NoError(t, err)
+		require.NotEmpty(t, reports)
+		last := reports[len(reports)-1]
+		assert.Equal(t, node.Progress{Name: "progress.bin", Bytes: int64(len(content)), Total: int64(len(content)), Rate: last.Rate}, last)
+		for i := 1; i < len(reports); i++ {
+			assert.GreaterOrEqual(t, reports[i].Bytes, reports[i-1].Bytes)
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		reports = nil
+		n, err := c.GetNodeTree().FindNode("/progress.bin")
+		require.NoError(t, err)
+		body, err := c.GetNodeTree().DownloadContext(ctx, n)
+		require.NoError(t, err)
+		defer body.Close()
+		_, err = io.Copy(io.Discard, body)
+		require.NoError(t, err)
+		require.NotEmpty(t, reports)
+		last := reports[len(reports)-1]
+		assert.Equal(t, int64(len(content)), last.Bytes)
+		assert.Equal(t, int64(len(content)), last.Total)
+		assert.Positive(t, last.Rate)
+	})
+
+	t.Run("download range", func(t *testing.T) {
+		reports = nil
+		n, err := c.GetNodeTree().FindNode("/progress.bin")
+		require.NoError(t, err)
+		body, err := c.GetNodeTree().DownloadRangeContext(ctx, n, 1000, -1)
+		require.NoError(t, err)
+		defer body.Close()
+		_, err = io.Copy(io.Discard, body)
+		require.NoError(t, err)
+		require.NotEmpty(t, reports)
+		last := reports[len(reports)-1]
+		assert.Equal(t, int64(len(content)-1000), last.Bytes)
+		assert.Equal(t, int64(len(content)-1000), last.Total)
+	})
+
+	t.Run("no progress without a function", func(t *testing.T) {
+		reports = nil
+		_, err := c.Upload("/other.bin", false, nil, node.NewProperty(), bytes.NewReader(content))
+		require.NoError(t, err)
+		assert.Empty(t, reports)
+	})
+}
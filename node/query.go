@@ -0,0 +1,271 @@
+package node
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+	"github.com/montaguethomas/acd-go/log"
+)
+
+// QuerySort is the order of the nodes returned by Tree.Query.
+type QuerySort string
+
+const (
+	// SortByPath sorts the nodes by lowercase path, the default.
+	SortByPath QuerySort = "path"
+	// SortByName sorts the nodes by lowercase name, then by path.
+	SortByName QuerySort = "name"
+	// SortBySize sorts the nodes by size, then by path.
+	SortBySize QuerySort = "size"
+	// SortByModifiedDate sorts the nodes by ModifiedDate, then by path.
+	SortByModifiedDate QuerySort = "modified"
+	// SortByCreatedDate sorts the nodes by CreatedDate, then by path.
+	SortByCreatedDate QuerySort = "created"
+)
+
+type (
+	// Query selects nodes of the tree by their metadata. The zero value of a
+	// field does not filter the nodes; a node must match every other field.
+	Query struct {
+		// Root is the path of the folder the nodes are under, the root of the
+		// tree by default. The folder itself is not selected.
+		Root string
+		// Name is a path.Match pattern matching the name of the nodes. The
+		// matching is case insensitive.
+		Name string
+		// Kinds are the kinds of the nodes, any of them.
+		Kinds []NodeKind
+		// Statuses are the statuses of the nodes, any of them.
+		Statuses []NodeStatus
+		// MinSize and MaxSize are the range of the size of the nodes in
+		// bytes, both included. The size of a folder is the size of its
+		// files.
+		MinSize uint64
+		MaxSize uint64
+		// ModifiedDate, CreatedDate and ContentDate are the ranges of the
+		// dates of the nodes.
+		ModifiedDate TimeRange
+		CreatedDate  TimeRange
+		ContentDate  TimeRange
+		// Extensions are the extensions of the nodes, with or without the
+		// leading dot, any of them. The comparison is case insensitive.
+		Extensions []string
+		// ContentTypes are path.Match patterns matching the content type of
+		// the nodes, such as "image/*", any of them.
+		ContentTypes []string
+		// Labels are the labels of the nodes, all of them.
+		Labels []string
+		// Properties are the owner properties of the nodes, all of them with
+		// the same value.
+		Properties map[string]string
+
+		// Sort is the order of the nodes, SortByPath by default. Descending
+		// reverses it.
+		Sort       QuerySort
+		Descending bool
+		// Limit is the maximum number of nodes returned, all of them if 0.
+		Limit int
+	}
+
+	// TimeRange is a range of dates, from After included until Before
+	// excluded. A zero date leaves the range open, and a node without the date
+	// is not in a range with a bound.
+	TimeRange struct {
+		After  time.Time
+		Before time.Time
+	}
+)
+
+// contains returns whether t is in the range.
+func (r TimeRange) contains(t time.Time) bool {
+	if t.IsZero() {
+		return r.After.IsZero() && r.Before.IsZero()
+	}
+	if !r.After.IsZero() && t.Before(r.After) {
+		return false
+	}
+	if !r.Before.IsZero() && !t.Before(r.Before) {
+		return false
+	}
+	return true
+}
+
+// Query returns the nodes of the tree selected by q, sorted and limited as
+// q requires. It only reads the tree, except in a lazy tree where the folders
+// are listed as they are walked. It returns path.ErrBadPattern for an invalid
+// pattern, constants.ErrUnknownQuerySort for an unknown sort and the error
+// listing the children of a folder.
+func (nt *Tree) Query(q *Query) ([]*Node, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	root := q.Root
+	if root == "" {
+		root = "/"
+	}
+
+	type result struct {
+		node *Node
+		path string
+	}
+	var results []result
+	// a node with several parents is walked once per parent, it is only
+	// one result.
+	seen := make(map[*Node]bool)
+	var walkedRoot bool
+	err := nt.Walk(root, func(p string, n *Node, err error) error {
+		if err != nil {
+			return err
+		}
+		if !walkedRoot {
+			// the folder of the query is not one of its results.
+			walkedRoot = true
+			return nil
+		}
+		if !seen[n] && q.match(n) {
+			seen[n] = true
+			results = append(results, result{node: n, path: strings.ToLower(p)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the size of a folder is the size of its subtree, it is computed once
+	// for the sort.
+	var sizes map[*Node]uint64
+	if q.Sort == SortBySize {
+		sizes = make(map[*Node]uint64, len(results))
+		for _, r := range results {
+			sizes[r.node] = r.node.Size()
+		}
+	}
+	compare := q.compare(sizes)
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if q.Descending {
+			a, b = b, a
+		}
+		if c := compare(a.node, b.node); c != 0 {
+			return c < 0
+		}
+		return a.path < b.path
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	nodes := make([]*Node, len(results))
+	for i, r := range results {
+		nodes[i] = r.node
+	}
+	return nodes, nil
+}
+
+// validate returns an error if a pattern or the sort of q is invalid.
+func (q *Query) validate() error {
+	patterns := append([]string{q.Name}, q.ContentTypes...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	switch q.Sort {
+	case "", SortByPath, SortByName, SortBySize, SortByModifiedDate, SortByCreatedDate:
+	default:
+		log.Errorf("%s: %q", constants.ErrUnknownQuerySort, q.Sort)
+		return constants.ErrUnknownQuerySort
+	}
+	return nil
+}
+
+// match returns whether the node is selected by the filters of q.
+func (q *Query) match(n *Node) bool {
+	// the size of a folder is the size of its subtree, it is only computed
+	// when filtered on.
+	if q.MinSize > 0 || q.MaxSize > 0 {
+		if size := n.Size(); size < q.MinSize || (q.MaxSize > 0 && size > q.MaxSize) {
+			return false
+		}
+	}
+	for key, want := range q.Properties {
+		if got, ok := n.GetOwnerProperty(key); !ok || got != want {
+			return false
+		}
+	}
+
+	n.RLock()
+	defer n.RUnlock()
+
+	if q.Name != "" {
+		if ok, _ := path.Match(strings.ToLower(q.Name), strings.ToLower(n.Name)); !ok {
+			return false
+		}
+	}
+	if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, n.Kind) {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, n.Status) {
+		return false
+	}
+	if !q.ModifiedDate.contains(n.ModifiedDate) || !q.CreatedDate.contains(n.CreatedDate) ||
+		!q.ContentDate.contains(n.ContentProperties.ContentDate) {
+		return false
+	}
+	if len(q.Extensions) > 0 && !q.matchExtension(n) {
+		return false
+	}
+	if len(q.ContentTypes) > 0 && !slices.ContainsFunc(q.ContentTypes, func(pattern string) bool {
+		ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(n.ContentProperties.ContentType))
+		return ok
+	}) {
+		return false
+	}
+	for _, label := range q.Labels {
+		if !slices.Contains(n.Labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchExtension returns whether the extension of the node, from its content
+// properties or its name, is one of q.Extensions. The node must be locked.
+func (q *Query) matchExtension(n *Node) bool {
+	ext := n.ContentProperties.Extension
+	if ext == "" {
+		ext = path.Ext(n.Name)
+	}
+	ext = strings.TrimPrefix(ext, ".")
+	return slices.ContainsFunc(q.Extensions, func(want string) bool {
+		return strings.EqualFold(strings.TrimPrefix(want, "."), ext)
+	})
+}
+
+// compare returns the comparison of two nodes for q.Sort, 0 if they are equal
+// and sorted by path. sizes are the sizes of the nodes for SortBySize.
+func (q *Query) compare(sizes map[*Node]uint64) func(a, b *Node) int {
+	switch q.Sort {
+	case SortByName:
+		return func(a, b *Node) int {
+			return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+	case SortBySize:
+		return func(a, b *Node) int { return cmp.Compare(sizes[a], sizes[b]) }
+	case SortByModifiedDate:
+		return func(a, b *Node) int { return a.ModTime().Compare(b.ModTime()) }
+	case SortByCreatedDate:
+		createdDate := func(n *Node) time.Time {
+			n.RLock()
+			defer n.RUnlock()
+			return n.CreatedDate
+		}
+		return func(a, b *Node) int { return createdDate(a).Compare(createdDate(b)) }
+	}
+	return func(a, b *Node) int { return 0 }
+}
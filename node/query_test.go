@@ -0,0 +1,101 @@
+package node
+
+import (
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/montaguethomas/acd-go/constants"
+)
+
+func TestQuery(t *testing.T) {
+	nt := newWalkTree("/photos/", "/photos/2023/", "/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png",
+		"/photos/cover.jpg", "/notes.txt", "/trash.txt")
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := map[string]struct {
+		size        uint64
+		modified    int
+		contentType string
+		labels      []string
+		album       string
+	}{
+		"/photos/2023/IMG_1.JPG": {300, 3, "image/jpeg", []string{"PHOTOS"}, "summer"},
+		"/photos/2023/img_2.png": {200, 2, "image/png", []string{"PHOTOS"}, "winter"},
+		"/photos/cover.jpg":      {100, 1, "image/jpeg", nil, "summer"},
+		"/notes.txt":             {50, 4, "text/plain", []string{"DOCUMENTS"}, ""},
+		"/trash.txt":             {10, 5, "text/plain", nil, ""},
+	}
+	for p, f := range files {
+		n := nt.nodeIdMap[p]
+		n.Status = StatusAvailable
+		n.ContentProperties.Size = f.size
+		n.ContentProperties.ContentType = f.contentType
+		n.ModifiedDate = day.AddDate(0, 0, f.modified)
+		n.CreatedDate = day.AddDate(0, 0, -f.modified)
+		n.Labels = f.labels
+		if f.album != "" {
+			props := NewProperty()
+			props.Set("album", f.album)
+			n.SetOwnerProperties(props)
+		}
+	}
+	nt.nodeIdMap["/trash.txt"].Status = StatusTrash
+
+	tests := map[string]struct {
+		query Query
+		want  []string
+	}{
+		"everything":    {Query{}, []string{"/notes.txt", "/photos", "/photos/2023", "/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png", "/photos/cover.jpg", "/trash.txt"}},
+		"root":          {Query{Root: "/Photos/2023"}, []string{"/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png"}},
+		"name":          {Query{Name: "IMG_*"}, []string{"/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png"}},
+		"kind":          {Query{Kinds: []NodeKind{KindFolder}}, []string{"/photos", "/photos/2023"}},
+		"status":        {Query{Statuses: []NodeStatus{StatusTrash}}, []string{"/trash.txt"}},
+		"size":          {Query{Kinds: []NodeKind{KindFile}, MinSize: 100, MaxSize: 200}, []string{"/photos/2023/img_2.png", "/photos/cover.jpg"}},
+		"folder size":   {Query{Kinds: []NodeKind{KindFolder}, MinSize: 600}, []string{"/photos"}},
+		"modified":      {Query{ModifiedDate: TimeRange{After: day.AddDate(0, 0, 2), Before: day.AddDate(0, 0, 4)}}, []string{"/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png"}},
+		"created":       {Query{CreatedDate: TimeRange{Before: day.AddDate(0, 0, -3)}}, []string{"/notes.txt", "/trash.txt"}},
+		"extension":     {Query{Extensions: []string{".jpg"}}, []string{"/photos/2023/IMG_1.JPG", "/photos/cover.jpg"}},
+		"content type":  {Query{ContentTypes: []string{"image/*"}, Statuses: []NodeStatus{StatusAvailable}}, []string{"/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png", "/photos/cover.jpg"}},
+		"labels":        {Query{Labels: []string{"PHOTOS"}}, []string{"/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png"}},
+		"properties":    {Query{Properties: map[string]string{"album": "summer"}}, []string{"/photos/2023/IMG_1.JPG", "/photos/cover.jpg"}},
+		"sort by size":  {Query{Kinds: []NodeKind{KindFile}, Sort: SortBySize, Limit: 3}, []string{"/trash.txt", "/notes.txt", "/photos/cover.jpg"}},
+		"sort folders":  {Query{Kinds: []NodeKind{KindFolder}, Sort: SortBySize, Descending: true}, []string{"/photos", "/photos/2023"}},
+		"sort by name":  {Query{Kinds: []NodeKind{KindFile}, Sort: SortByName, Descending: true}, []string{"/trash.txt", "/notes.txt", "/photos/2023/img_2.png", "/photos/2023/IMG_1.JPG", "/photos/cover.jpg"}},
+		"sort modified": {Query{Kinds: []NodeKind{KindFile}, Sort: SortByModifiedDate, Descending: true, Limit: 2}, []string{"/trash.txt", "/notes.txt"}},
+		"sort created":  {Query{Kinds: []NodeKind{KindFile}, Sort: SortByCreatedDate, Limit: 2}, []string{"/trash.txt", "/notes.txt"}},
+	}
+	for name, test := range tests {
+		nodes, err := nt.Query(&test.query)
+		if err != nil {
+			t.Errorf("%s: Query() error: %s", name, err)
+			continue
+		}
+		var got []string
+		for _, n := range nodes {
+			p, _ := nt.PathOf(n)
+			got = append(got, p)
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: Query(): want %q got %q", name, test.want, got)
+		}
+	}
+
+	// a node with two parents is one result.
+	cover := nt.nodeIdMap["/photos/cover.jpg"]
+	cover.Parents = append(cover.Parents, "/photos/2023")
+	nt.attach(nt.nodeIdMap["/photos/2023"], cover)
+	if nodes, err := nt.Query(&Query{Name: "cover.jpg", Sort: SortByCreatedDate}); err != nil || len(nodes) != 1 {
+		t.Errorf("Query() a node with two parents: want 1 node got %d, %v", len(nodes), err)
+	}
+
+	if _, err := nt.Query(&Query{Name: "[a"}); err != path.ErrBadPattern {
+		t.Errorf("Query() a bad pattern: want %s got %v", path.ErrBadPattern, err)
+	}
+	if _, err := nt.Query(&Query{Sort: "color"}); err != constants.ErrUnknownQuerySort {
+		t.Errorf("Query() an unknown sort: want %s got %v", constants.ErrUnknownQuerySort, err)
+	}
+	if _, err := nt.Query(&Query{Root: "/missing"}); err == nil {
+		t.Errorf("Query() a missing root: want an error got nil")
+	}
+}
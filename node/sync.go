@@ -3,7 +3,9 @@ package node
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -42,15 +44,63 @@ type (
 
 // Sync syncs the tree with the server.
 func (nt *Tree) Sync() error {
+	return nt.SyncContext(context.Background())
+}
+
+// SyncContext is like Sync but uses ctx for the changes request. Cancelling
+// ctx aborts the sync while streaming the changes; the checkpoint is only
+// advanced for the chunks that were fully applied.
+func (nt *Tree) SyncContext(ctx context.Context) error {
 	log.Debug("node.Tree Sync starting.")
 	defer log.Debug("node.Tree Sync completed.")
 
+	// the background sync and the calls to Sync take turns.
+	nt.syncMu.Lock()
+	defer nt.syncMu.Unlock()
+
+	// A lazy tree only drops what has changed, the folders are listed again
+	// when they are used.
+	if nt.lazy {
+		nt.loadMu.Lock()
+		checkpointed := nt.checkpointed
+		nt.loadMu.Unlock()
+		if !checkpointed {
+			return nt.fetchCheckpoint(ctx)
+		}
+		return nt.fetchChanges(ctx, nt.invalidateNodes)
+	}
+	if err := nt.fetchChanges(ctx, nt.updateNodes); err != nil {
+		return err
+	}
+
+	// Rebuild the full node tree
+	nt.buildNodeTree()
+
+	// Save the cache after the updates
+	if err := nt.saveCache(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// fetchChanges streams the changes since the checkpoint of the tree and calls
+// apply with the nodes of every chunk, advancing the checkpoint once a chunk
+// is applied.
+func (nt *Tree) fetchChanges(ctx context.Context, apply func([]*Node) error) error {
 	// Build Request Body
-	log.Debugf("current nodeTree.checkpoint %s", nt.Checkpoint)
+	nt.RLock()
+	checkpoint := nt.Checkpoint
+	nt.RUnlock()
+	log.Debugf("current nodeTree.checkpoint %s", checkpoint)
 	c := &apiChangesRequest{
-		Checkpoint: nt.Checkpoint,
+		Checkpoint: checkpoint,
 		ChunkSize:  nt.chunkSize,
 	}
+	// the purged nodes are only needed to report them to the subscribers.
+	if nt.hasSubscribers() {
+		c.IncludePurged = "true"
+	}
 	jsonBytes, err := json.Marshal(c)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrJSONEncoding, err)
@@ -58,7 +108,7 @@ func (nt *Tree) Sync() error {
 	}
 
 	// Build Request
-	req, err := http.NewRequest("POST", nt.client.GetMetadataURL("changes"), bytes.NewBuffer(jsonBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", nt.client.GetMetadataURL("changes"), bytes.NewBuffer(jsonBytes))
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return constants.ErrCreatingHTTPRequest
@@ -69,7 +119,7 @@ func (nt *Tree) Sync() error {
 	res, err := nt.client.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return constants.ErrDoingHTTPRequest
+		return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
 	}
 	if err := nt.client.CheckResponse(res); err != nil {
 		return err
@@ -85,6 +135,11 @@ func (nt *Tree) Sync() error {
 	// Process response body by line of json
 	scanner := bufio.NewScanner(res.Body)
 	for scanner.Scan() {
+		// the chunks already read from the response are not applied either.
+		if err := ctx.Err(); err != nil {
+			log.Errorf("%s: %s", constants.ErrReadingResponseBody, err)
+			return fmt.Errorf("%w: %w", constants.ErrReadingResponseBody, err)
+		}
 		lineBytes := scanner.Bytes()
 
 		var cr apiChangesResponse
@@ -99,8 +154,20 @@ func (nt *Tree) Sync() error {
 			break
 		}
 
+		// A chunk of the stream can carry its own error status.
+		if cr.StatusCode != 0 && (cr.StatusCode < 200 || cr.StatusCode > 299) {
+			apiErr := &constants.APIError{
+				StatusCode: cr.StatusCode,
+				Method:     req.Method,
+				URL:        req.URL.Redacted(),
+				Body:       string(lineBytes),
+			}
+			log.Errorf("%s", apiErr)
+			return apiErr
+		}
+
 		log.Debugf("syncing checkpoint %s", cr.Checkpoint)
-		if err := nt.updateNodes(cr.Nodes); err != nil {
+		if err := apply(cr.Nodes); err != nil {
 			return err
 		}
 
@@ -112,19 +179,14 @@ func (nt *Tree) Sync() error {
 	}
 
 	// Check for an error from the scanner
-	if scanner.Err() != nil {
+	if err := scanner.Err(); err != nil {
 		log.Errorf("%s: %s", constants.ErrReadingResponseBody, err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("%w: %w", constants.ErrReadingResponseBody, ctxErr)
+		}
 		return constants.ErrReadingResponseBody
 	}
 
-	// Rebuild the full node tree
-	nt.buildNodeTree()
-
-	// Save the cache after the updates
-	if err := nt.saveCache(); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -138,18 +200,27 @@ func (nt *Tree) updateNodes(crNodes []*Node) error {
 			nt.Node = crNode
 			nt.nodeIdMap[crNode.Id] = crNode
 			nt.Unlock()
+			nt.resetIndex()
 			continue
 		}
 
 		// Remove deleted nodes
 		if !crNode.IsAvailable() {
 			log.Tracef("node Id %s name %s has been deleted", crNode.Id, crNode.Name)
+			nt.RLock()
+			node, ok := nt.nodeIdMap[crNode.Id]
+			nt.RUnlock()
+			old, oldPath := nt.before(node)
 			nt.removeNodeFromTree(crNode)
+			if ok || crNode.Status == StatusPurged {
+				nt.emitRemoved(old, crNode, oldPath)
+			}
 			continue
 		}
 
 		// Get existing node or create it
 		node, ok := nt.nodeIdMap[crNode.Id]
+		old, oldPath := nt.before(node)
 		if !ok {
 			nt.Lock()
 			nt.nodeIdMap[crNode.Id] = crNode
@@ -171,7 +242,7 @@ func (nt *Tree) updateNodes(crNodes []*Node) error {
 					log.Tracef("parent Id %s not found, nothing to remove from", parentId)
 					continue
 				}
-				parent.removeChild(node)
+				nt.detach(parent, node)
 			}
 		}
 
@@ -183,7 +254,13 @@ func (nt *Tree) updateNodes(crNodes []*Node) error {
 				parent = &Node{Id: parentId}
 				nt.nodeIdMap[parentId] = parent
 			}
-			parent.addChild(crNode)
+			nt.attach(parent, crNode)
+		}
+
+		if ok {
+			nt.emitChanges(old, crNode, oldPath)
+		} else {
+			nt.emitCreated(crNode)
 		}
 	}
 
@@ -0,0 +1,37 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SyncContextCancel(t *testing.T) {
+	c, server := newTestClient(t)
+	nt := c.GetNodeTree()
+	// the changes are streamed in chunks of 25 nodes.
+	for i := 0; i < 100; i++ {
+		_, err := server.PutFile(fmt.Sprintf("/sync/%03d.txt", i), []byte("sync"))
+		require.NoError(t, err)
+	}
+	checkpoint := nt.Checkpoint
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	unsubscribe := nt.Subscribe(func(node.Event) { cancel() })
+	err := nt.SyncContext(ctx)
+	unsubscribe()
+	assert.True(t, errors.Is(err, context.Canceled), "SyncContext() error: %v", err)
+	assert.NotEqual(t, checkpoint, nt.Checkpoint, "the first chunk is applied")
+	_, err = nt.FindNode("/sync/099.txt")
+	assert.Error(t, err, "the last chunk is not applied")
+
+	require.NoError(t, nt.Sync())
+	_, err = nt.FindNode("/sync/099.txt")
+	assert.NoError(t, err)
+}
@@ -1,8 +1,11 @@
 package node
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -23,9 +26,28 @@ type (
 		cacheFile string
 		chunkSize int
 		client    client
+		mkdirMu   sync.Mutex
 		mutex     sync.RWMutex
 		nodeIdMap map[string]*Node
+		index     *pathIndex
 		syncDone  chan struct{}
+		syncMu    sync.Mutex
+
+		eventsMu       sync.Mutex
+		nextSubscriber int
+		subscribers    map[int]EventFunc
+
+		// lazy trees only, see NewLazyTree. loadMu guards loadedAt, folderMu,
+		// invalidations and checkpointed, folderMu serializes the listings of
+		// a folder.
+		lazy          bool
+		lazyTTL       time.Duration
+		loadMu        sync.Mutex
+		loadedAt      map[string]time.Time
+		folderMu      map[string]*sync.Mutex
+		invalidations uint64
+		checkpointed  bool
+		now           func() time.Time
 	}
 
 	// Amazon Cloud Drive Client interface
@@ -35,6 +57,10 @@ type (
 		Do(*http.Request) (*http.Response, error)
 		CheckResponse(*http.Response) error
 		GetNodeTree() *Tree
+		// GetUploadLimiter and GetDownloadLimiter return the Limiter of the
+		// transfers of a direction, nil if they are not limited.
+		GetUploadLimiter() Limiter
+		GetDownloadLimiter() Limiter
 	}
 )
 
@@ -56,30 +82,45 @@ func NewTree(c client, cacheFile string, chunkSize int, syncInterval time.Durati
 		return nil, err
 	}
 
-	ticker := time.NewTicker(syncInterval)
+	nt.startSync(syncInterval)
+	return nt, nil
+}
+
+// startSync syncs the tree in the background every interval until Close.
+func (nt *Tree) startSync(interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	nt.syncDone = make(chan struct{}, 1)
+	sync := func() {
+		log.Debug("Background sync starting.")
+		if err := nt.Sync(); err != nil {
+			log.Errorf("Background sync error: %s", err)
+		}
+		log.Debug("Background sync completed.")
+	}
 	go func() {
+		// a lazy tree gets the checkpoint of the changes right away.
+		if nt.lazy {
+			sync()
+		}
 		for {
 			select {
 			case <-nt.syncDone:
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				log.Debug("Background sync starting.")
-				if err := nt.Sync(); err != nil {
-					log.Errorf("Background sync error: %s", err)
-				}
-				log.Debug("Background sync completed.")
+				sync()
 			}
 		}
 	}()
-
-	return nt, nil
 }
 
 // Close finalizes the NodeTree
 func (nt *Tree) Close() error {
 	nt.syncDone <- struct{}{}
+	if nt.lazy {
+		// a partial tree must not be loaded as the full tree.
+		return nil
+	}
 	return nt.saveCache()
 }
 
@@ -98,8 +139,14 @@ func (nt *Tree) RUnlock() {
 
 // RemoveNode removes this node from the server and from the NodeTree.
 func (nt *Tree) RemoveNode(n *Node) error {
+	return nt.RemoveNodeContext(context.Background(), n)
+}
+
+// RemoveNodeContext is like RemoveNode but uses ctx for the request.
+func (nt *Tree) RemoveNodeContext(ctx context.Context, n *Node) error {
+	old, oldPath := nt.before(n)
 	putURL := nt.client.GetMetadataURL(fmt.Sprintf("/trash/%s", n.Id))
-	req, err := http.NewRequest("PUT", putURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "PUT", putURL, nil)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return constants.ErrCreatingHTTPRequest
@@ -107,16 +154,136 @@ func (nt *Tree) RemoveNode(n *Node) error {
 	res, err := nt.client.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return constants.ErrDoingHTTPRequest
+		return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
 	}
 	if err := nt.client.CheckResponse(res); err != nil {
 		return err
 	}
+	res.Body.Close()
 
 	nt.removeNodeFromTree(n)
+	if trashed := nt.snapshot(n); trashed != nil {
+		trashed.Status = StatusTrash
+		nt.emitRemoved(old, trashed, oldPath)
+	}
 	return nil
 }
 
+// AddNode inserts the node in the tree, under its parents, to reflect a change
+// made outside of the Tree such as restoring the node from the trash. If the
+// tree already has a node with the same Id, that node is updated and moved
+// instead. The children of a folder which are in the tree are attached to it.
+// It returns the node of the tree.
+func (nt *Tree) AddNode(n *Node) (*Node, error) {
+	return nt.addNode(n, nil)
+}
+
+// AddNodes is like AddNode for several nodes, such as the nodes restored
+// together from the trash, looking up the children of all the folders in a
+// single pass over the tree. It returns the nodes of the tree which have been
+// added and the errors of the other nodes joined.
+func (nt *Tree) AddNodes(nodes []*Node) ([]*Node, error) {
+	var children map[string][]*Node
+	for _, n := range nodes {
+		if n.IsDir() {
+			children = nt.childrenByParent()
+			break
+		}
+	}
+
+	added := make([]*Node, 0, len(nodes))
+	var errs []error
+	for _, n := range nodes {
+		tn, err := nt.addNode(n, children)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", n.Id, err))
+			continue
+		}
+		added = append(added, tn)
+	}
+	return added, errors.Join(errs...)
+}
+
+// childrenByParent returns the nodes of the tree by the Ids of their parents.
+func (nt *Tree) childrenByParent() map[string][]*Node {
+	children := make(map[string][]*Node)
+	nt.RLock()
+	defer nt.RUnlock()
+	for _, child := range nt.nodeIdMap {
+		child.RLock()
+		for _, parentId := range child.Parents {
+			children[parentId] = append(children[parentId], child)
+		}
+		child.RUnlock()
+	}
+	return children
+}
+
+// addNode adds the node like AddNode. The children of a folder are looked up
+// in children, which is updated with the node, or in the whole tree if it is
+// nil.
+func (nt *Tree) addNode(n *Node, children map[string][]*Node) (*Node, error) {
+	nt.RLock()
+	existing, ok := nt.nodeIdMap[n.Id]
+	nt.RUnlock()
+	old, oldPath := nt.before(existing)
+	if ok && existing != n {
+		nt.removeNodeFromTree(existing)
+		if err := existing.update(n); err != nil {
+			return nil, err
+		}
+		n = existing
+	} else if ok {
+		nt.removeNodeFromTree(n)
+	}
+	nt.addNodeToNodeIdMap(n)
+
+	n.RLock()
+	parentIds, isDir := slices.Clone(n.Parents), n.Kind == KindFolder
+	n.RUnlock()
+	var parents []*Node
+	nt.RLock()
+	for _, parentId := range parentIds {
+		parent, ok := nt.nodeIdMap[parentId]
+		if !ok {
+			log.Tracef("node.Tree AddNode parent Id %s not found", parentId)
+			continue
+		}
+		parents = append(parents, parent)
+	}
+	nt.RUnlock()
+	var candidates []*Node
+	if isDir && children == nil {
+		candidates = nt.childrenByParent()[n.Id]
+	} else if isDir {
+		candidates = children[n.Id]
+	}
+	if children != nil {
+		for _, parentId := range parentIds {
+			children[parentId] = append(children[parentId], n)
+		}
+	}
+
+	for _, parent := range parents {
+		nt.attach(parent, n)
+	}
+	for _, child := range candidates {
+		// the parents of the child may have changed since it was indexed.
+		child.RLock()
+		isChild := slices.Contains(child.Parents, n.Id)
+		child.RUnlock()
+		if isChild {
+			nt.attach(n, child)
+		}
+	}
+	if ok {
+		nt.emitChanges(old, n, oldPath)
+	} else {
+		nt.emitCreated(n)
+	}
+	return n, nil
+}
+
 func (nt *Tree) addNodeToNodeIdMap(n *Node) {
 	nt.Lock()
 	n.RLock()
@@ -127,18 +294,23 @@ func (nt *Tree) addNodeToNodeIdMap(n *Node) {
 
 func (nt *Tree) removeNodeFromTree(n *Node) {
 	n.RLock()
-	defer n.RUnlock()
+	parentIds := slices.Clone(n.Parents)
+	n.RUnlock()
 
+	var parents []*Node
 	nt.RLock()
-	for _, parentId := range n.Parents {
+	for _, parentId := range parentIds {
 		parent, ok := nt.nodeIdMap[parentId]
 		if !ok {
 			log.Tracef("node.Tree removeNodeFromTree parent Id %s not found", parentId)
 			continue
 		}
-		parent.removeChild(n)
+		parents = append(parents, parent)
 	}
 	nt.RUnlock()
+	for _, parent := range parents {
+		nt.detach(parent, n)
+	}
 	nt.Lock()
 	delete(nt.nodeIdMap, n.Id)
 	nt.Unlock()
@@ -149,20 +321,21 @@ func (nt *Tree) removeNodeFromTree(n *Node) {
 // already a directory, MkDirAll does nothing and returns the directory node
 // and nil.
 func (nt *Tree) MkDirAll(path string) (*Node, error) {
+	return nt.MkDirAllContext(context.Background(), path)
+}
+
+// MkDirAllContext is like MkDirAll but uses ctx for the requests creating the
+// missing folders.
+func (nt *Tree) MkDirAllContext(ctx context.Context, path string) (*Node, error) {
 	var (
 		err        error
 		folderNode = nt.Node
-		logLevel   = log.GetLevel()
 		nextNode   *Node
 		node       *Node
 	)
 
 	// Short-circuit if the node already exists!
-	{
-		log.SetLevel(log.DisableLogLevel)
-		node, err = nt.FindNode(path)
-		log.SetLevel(logLevel)
-	}
+	node, err = nt.findNode(path)
 	if err == nil {
 		if node.IsDir() {
 			return node, err
@@ -179,17 +352,17 @@ func (nt *Tree) MkDirAll(path string) (*Node, error) {
 		return nil, constants.ErrCannotCreateRootNode
 	}
 
+	// the folders are created one at a time so concurrent calls never create
+	// two siblings with the same name.
+	nt.mkdirMu.Lock()
+	defer nt.mkdirMu.Unlock()
 	for i, part := range parts {
-		{
-			log.SetLevel(log.DisableLogLevel)
-			nextNode, err = nt.FindNode(strings.Join(parts[:i+1], "/"))
-			log.SetLevel(logLevel)
-		}
+		nextNode, err = nt.findNode(strings.Join(parts[:i+1], "/"))
 		if err != nil && err != constants.ErrNodeNotFound {
 			return nil, err
 		}
 		if err == constants.ErrNodeNotFound {
-			nextNode, err = nt.CreateFolder(folderNode, part, []string{}, NewProperty())
+			nextNode, err = nt.CreateFolderContext(ctx, folderNode, part, []string{}, NewProperty())
 			if err != nil {
 				return nil, err
 			}
@@ -232,7 +405,7 @@ func (nt *Tree) buildNodeTree() {
 		}
 		for _, parentId := range node.Parents {
 			if parent, ok := nt.nodeIdMap[parentId]; ok {
-				parent.addChild(node)
+				nt.attach(parent, node)
 			}
 		}
 	}
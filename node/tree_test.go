@@ -0,0 +1,25 @@
+package node
+
+import "testing"
+
+func TestAddNodes(t *testing.T) {
+	nt := newWalkTree("/photos/", "/photos/a.jpg")
+	// the children of a removed folder stay in the tree.
+	photos := nt.nodeIdMap["/photos"]
+	nt.removeNodeFromTree(photos)
+	docs := &Node{Id: "/docs", Name: "docs", Kind: KindFolder, Parents: []string{"/"}}
+	notes := &Node{Id: "/docs/notes.txt", Name: "notes.txt", Kind: KindFile, Parents: []string{"/docs"}}
+
+	added, err := nt.AddNodes([]*Node{notes, photos, docs})
+	if err != nil {
+		t.Fatalf("AddNodes() error: %s", err)
+	}
+	if want, got := 3, len(added); want != got {
+		t.Errorf("AddNodes(): want %d nodes got %d", want, got)
+	}
+	for _, p := range []string{"/photos/a.jpg", "/docs/notes.txt"} {
+		if _, err := nt.findNode(p); err != nil {
+			t.Errorf("findNode(%q) error: %s", p, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,6 +15,11 @@ import (
 
 // CreateFolder creates the named folder under the node
 func (nt *Tree) CreateFolder(n *Node, name string, labels []string, properties Property) (*Node, error) {
+	return nt.CreateFolderContext(context.Background(), n, name, labels, properties)
+}
+
+// CreateFolderContext is like CreateFolder but uses ctx for the request.
+func (nt *Tree) CreateFolderContext(ctx context.Context, n *Node, name string, labels []string, properties Property) (*Node, error) {
 	n.RLock()
 	cn := &newNode{
 		Name:    name,
@@ -31,7 +37,7 @@ func (nt *Tree) CreateFolder(n *Node, name string, labels []string, properties P
 		return nil, constants.ErrJSONEncoding
 	}
 
-	req, err := http.NewRequest("POST", nt.client.GetMetadataURL("nodes"), bytes.NewBuffer(jsonBytes))
+	req, err := http.NewRequestWithContext(ctx, "POST", nt.client.GetMetadataURL("nodes"), bytes.NewBuffer(jsonBytes))
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return nil, constants.ErrCreatingHTTPRequest
@@ -41,7 +47,7 @@ func (nt *Tree) CreateFolder(n *Node, name string, labels []string, properties P
 	res, err := nt.client.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return nil, constants.ErrDoingHTTPRequest
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
 	}
 	if err := nt.client.CheckResponse(res); err != nil {
 		return nil, err
@@ -57,12 +63,19 @@ func (nt *Tree) CreateFolder(n *Node, name string, labels []string, properties P
 	nt.Lock()
 	nt.nodeIdMap[node.Id] = node
 	nt.Unlock()
-	n.addChild(node)
+	nt.attach(n, node)
+	nt.emitCreated(node)
 	return node, nil
 }
 
 // Upload writes contents of r as name inside the current node.
 func (nt *Tree) Upload(parent *Node, name string, labels []string, properties Property, r io.Reader) (*Node, error) {
+	return nt.UploadContext(context.Background(), parent, name, labels, properties, r)
+}
+
+// UploadContext is like Upload but uses ctx for the request. Cancelling ctx
+// aborts the upload while r is being streamed.
+func (nt *Tree) UploadContext(ctx context.Context, parent *Node, name string, labels []string, properties Property, r io.Reader) (*Node, error) {
 	metadata := &newNode{
 		Name:    name,
 		Kind:    "FILE",
@@ -79,18 +92,33 @@ func (nt *Tree) Upload(parent *Node, name string, labels []string, properties Pr
 	}
 
 	postURL := nt.client.GetContentURL("nodes?suppress=deduplication")
-	node, err := nt.upload(parent, postURL, "POST", string(metadataJSON), name, r)
+	node, err := nt.upload(ctx, parent, postURL, "POST", string(metadataJSON), name, r)
 	if err != nil {
 		return nil, err
 	}
 
 	nt.addNodeToNodeIdMap(node)
-	parent.addChild(node)
+	nt.attach(parent, node)
+	nt.emitCreated(node)
 	return node, nil
 }
 
 // Patch updates metadata for the provided node.
 func (nt *Tree) Patch(n *Node, labels []string, properties Property) error {
+	return nt.PatchContext(context.Background(), n, labels, properties)
+}
+
+// PatchContext is like Patch but uses ctx for the request.
+func (nt *Tree) PatchContext(ctx context.Context, n *Node, labels []string, properties Property) error {
+	old, oldPath := nt.before(n)
+	if err := nt.patch(ctx, n, labels, properties); err != nil {
+		return err
+	}
+	nt.emitChanges(old, n, oldPath)
+	return nil
+}
+
+func (nt *Tree) patch(ctx context.Context, n *Node, labels []string, properties Property) error {
 	metadata := &patchNode{
 		Labels: labels,
 		Properties: map[string]Property{
@@ -104,7 +132,7 @@ func (nt *Tree) Patch(n *Node, labels []string, properties Property) error {
 	}
 
 	patchURL := nt.client.GetMetadataURL(fmt.Sprintf("nodes/%s", n.Id))
-	req, err := http.NewRequest("PATCH", patchURL, bytes.NewBuffer(metadataJSON))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", patchURL, bytes.NewBuffer(metadataJSON))
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
 		return constants.ErrCreatingHTTPRequest
@@ -113,7 +141,7 @@ func (nt *Tree) Patch(n *Node, labels []string, properties Property) error {
 	res, err := nt.client.Do(req)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-		return constants.ErrDoingHTTPRequest
+		return fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
 	}
 	if err := nt.client.CheckResponse(res); err != nil {
 		return err
@@ -131,8 +159,15 @@ func (nt *Tree) Patch(n *Node, labels []string, properties Property) error {
 
 // Overwrite writes contents of r as name inside the current node.
 func (nt *Tree) Overwrite(n *Node, labels []string, properties Property, r io.Reader) error {
+	return nt.OverwriteContext(context.Background(), n, labels, properties, r)
+}
+
+// OverwriteContext is like Overwrite but uses ctx for the requests. Cancelling
+// ctx aborts the upload while r is being streamed.
+func (nt *Tree) OverwriteContext(ctx context.Context, n *Node, labels []string, properties Property, r io.Reader) error {
+	old, oldPath := nt.before(n)
 	putURL := nt.client.GetContentURL(fmt.Sprintf("nodes/%s/content", n.Id))
-	node, err := nt.upload(n, putURL, "PUT", "", n.Name, r)
+	node, err := nt.upload(ctx, n, putURL, "PUT", "", n.Name, r)
 	if err != nil {
 		return err
 	}
@@ -140,79 +175,80 @@ func (nt *Tree) Overwrite(n *Node, labels []string, properties Property, r io.Re
 	if err := n.update(node); err != nil {
 		return err
 	}
-	return nt.Patch(n, labels, properties)
+	if err := nt.patch(ctx, n, labels, properties); err != nil {
+		return err
+	}
+	nt.emitChanges(old, n, oldPath)
+	return nil
 }
 
-func (nt *Tree) upload(n *Node, url, method, metadataJSON, name string, r io.Reader) (*Node, error) {
+func (nt *Tree) upload(ctx context.Context, n *Node, url, method, metadataJSON, name string, r io.Reader) (*Node, error) {
 	bodyReader, bodyWriter := io.Pipe()
-	errChan := make(chan error)
-	bodyChan := make(chan io.ReadCloser)
-	contentTypeChan := make(chan string)
-
-	go n.bodyWriter(metadataJSON, name, r, bodyWriter, errChan, contentTypeChan)
-	go func() {
-		req, err := http.NewRequest(method, url, bodyReader)
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
-			select {
-			case errChan <- constants.ErrCreatingHTTPRequest:
-			default:
-			}
-			return
-		}
-		req.Header.Add("Content-Type", <-contentTypeChan)
-		res, err := nt.client.Do(req) // this should block until the upload is finished.
-		if err != nil {
-			log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
-			select {
-			case errChan <- constants.ErrDoingHTTPRequest:
-			default:
-			}
-			return
-		}
-		if err := nt.client.CheckResponse(res); err != nil {
-			select {
-			case errChan <- err:
-			default:
-			}
-			return
-		}
+	writer := multipart.NewWriter(bodyWriter)
+	errChan := make(chan error, 1)
 
-		select {
-		case bodyChan <- res.Body:
-		default:
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		log.Errorf("%s: %s", constants.ErrCreatingHTTPRequest, err)
+		return nil, constants.ErrCreatingHTTPRequest
+	}
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+
+	r = newProgressReader(ctx, newLimitedReader(ctx, r, nt.client.GetUploadLimiter()), name, readerSize(r))
+	go n.bodyWriter(ctx, metadataJSON, name, r, writer, bodyWriter, errChan)
+	res, err := nt.client.Do(req) // this should block until the upload is finished.
+	// Unblock the body writer if the request ended before the body was fully
+	// consumed and wait for it to be done, unless ctx is done while r blocks.
+	bodyReader.Close()
+	var writeErr error
+	select {
+	case writeErr = <-errChan:
+	case <-ctx.Done():
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, ctxErr)
+			return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, ctxErr)
 		}
-	}()
-
-	for {
-		select {
-		case err := <-errChan:
-			if err != nil {
-				return nil, err
-			}
-		case body := <-bodyChan:
-			defer body.Close()
-			var node Node
-			if err := json.NewDecoder(body).Decode(&node); err != nil {
-				log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
-				return nil, constants.ErrJSONDecodingResponseBody
-			}
-
-			return &node, nil
+		// an error writing the body is the reason the request failed.
+		if writeErr != nil {
+			return nil, writeErr
 		}
+		log.Errorf("%s: %s", constants.ErrDoingHTTPRequest, err)
+		return nil, fmt.Errorf("%w: %w", constants.ErrDoingHTTPRequest, err)
+	}
+	// the server can reject the request before reading the entire body, its
+	// response takes precedence over the error writing the body.
+	if err := nt.client.CheckResponse(res); err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if writeErr != nil {
+		return nil, writeErr
 	}
+
+	var node Node
+	if err := json.NewDecoder(res.Body).Decode(&node); err != nil {
+		log.Errorf("%s: %s", constants.ErrJSONDecodingResponseBody, err)
+		return nil, constants.ErrJSONDecodingResponseBody
+	}
+
+	return &node, nil
 }
 
-func (n *Node) bodyWriter(metadataJSON, name string, r io.Reader, bodyWriter io.WriteCloser, errChan chan error, contentTypeChan chan string) {
-	writer := multipart.NewWriter(bodyWriter)
-	contentTypeChan <- writer.FormDataContentType()
+// bodyWriter writes the multipart body of an upload to bodyWriter. The outcome
+// is always sent on errChan; on error bodyWriter is closed with it so the
+// request reading from the pipe fails too.
+func (n *Node) bodyWriter(ctx context.Context, metadataJSON, name string, r io.Reader, writer *multipart.Writer, bodyWriter *io.PipeWriter, errChan chan<- error) {
+	fail := func(err error) {
+		bodyWriter.CloseWithError(err)
+		errChan <- err
+	}
+
 	if metadataJSON != "" {
 		if err := writer.WriteField("metadata", metadataJSON); err != nil {
 			log.Errorf("%s: %s", constants.ErrWritingMetadata, err)
-			select {
-			case errChan <- constants.ErrWritingMetadata:
-			default:
-			}
+			fail(constants.ErrWritingMetadata)
 			return
 		}
 	}
@@ -220,35 +256,37 @@ func (n *Node) bodyWriter(metadataJSON, name string, r io.Reader, bodyWriter io.
 	part, err := writer.CreateFormFile("content", name)
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrCreatingWriterFromFile, err)
-		select {
-		case errChan <- err:
-		default:
-		}
+		fail(constants.ErrCreatingWriterFromFile)
 		return
 	}
-	count, err := io.Copy(part, r)
+	count, err := io.Copy(part, &contextReader{ctx: ctx, r: r})
 	if err != nil {
 		log.Errorf("%s: %s", constants.ErrWritingFileContents, err)
-		select {
-		case errChan <- constants.ErrWritingFileContents:
-		default:
-		}
+		fail(fmt.Errorf("%w: %w", constants.ErrWritingFileContents, err))
 		return
 	}
 	if count == 0 {
-		select {
-		case errChan <- constants.ErrNoContentsToUpload:
-		default:
-		}
+		fail(constants.ErrNoContentsToUpload)
 		return
 	}
 
-	select {
-	case errChan <- writer.Close():
-	default:
+	if err := writer.Close(); err != nil {
+		log.Errorf("%s: %s", constants.ErrWritingFileContents, err)
+		fail(fmt.Errorf("%w: %w", constants.ErrWritingFileContents, err))
+		return
 	}
-	select {
-	case errChan <- bodyWriter.Close():
-	default:
+	errChan <- bodyWriter.Close()
+}
+
+// contextReader is an io.Reader that stops reading from r once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return cr.r.Read(p)
 }
@@ -0,0 +1,45 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/montaguethomas/acd-go/node"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// endlessReader reads zeros forever, calling cancel once limit bytes are read.
+type endlessReader struct {
+	n, limit int
+	cancel   context.CancelFunc
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	clear(p)
+	r.n += len(p)
+	if r.n >= r.limit {
+		r.cancel()
+	}
+	return len(p), nil
+}
+
+func Test_UploadContextCancel(t *testing.T) {
+	c, server := newTestClient(t)
+	nt := c.GetNodeTree()
+	root, err := nt.FindNode("/")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &endlessReader{limit: 1 << 20, cancel: cancel}
+	_, err = nt.UploadContext(ctx, root, "endless.bin", nil, node.NewProperty(), r)
+	assert.True(t, errors.Is(err, context.Canceled), "UploadContext() error: %v", err)
+	assert.GreaterOrEqual(t, r.n, r.limit, "the body was streaming")
+
+	_, ok := server.Lookup("/endless.bin")
+	assert.False(t, ok, "the file is not created")
+	_, err = nt.FindNode("/endless.bin")
+	assert.Error(t, err)
+}
@@ -0,0 +1,167 @@
+package node
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// WalkFunc is called by Tree.Walk for every node. If the root cannot be
+// found, it is called once with a nil node and the error. If the children of
+// a folder cannot be listed in a lazy tree, it is called a second time for
+// the folder with the error; unless it returns an error, the walk goes on
+// with the children already in the tree, as with fs.WalkDirFunc. Returning
+// fs.SkipDir skips the children of a folder, or the remaining siblings of a
+// file, and returning fs.SkipAll stops the walk without an error. Any other
+// error stops the walk and is returned by Walk.
+type WalkFunc func(path string, n *Node, err error) error
+
+// Walk walks the nodes under the root path, root included, in lexical order
+// of their lowercase names. The paths passed to fn start with the path of the
+// root as it is in the tree.
+func (nt *Tree) Walk(root string, fn WalkFunc) error {
+	n, err := nt.findNode(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		rootPath, pathErr := nt.PathOf(n)
+		if pathErr != nil {
+			rootPath = normalizePath(root)
+		}
+		err = nt.walk(rootPath, n, fn)
+	}
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (nt *Tree) walk(p string, n *Node, fn WalkFunc) error {
+	if err := fn(p, n, nil); err != nil || !n.IsDir() {
+		if err == fs.SkipDir && n.IsDir() {
+			return nil
+		}
+		return err
+	}
+	children, err := nt.childrenOf(n)
+	if err != nil {
+		if err := fn(p, n, err); err != nil {
+			if err == fs.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	for _, child := range sortedChildren(children) {
+		if err := nt.walk(path.Join(p, child.Name), child, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedChildren sorts the children in lexical order of their lowercase names.
+func sortedChildren(children []*Node) []*Node {
+	sort.Slice(children, func(i, j int) bool {
+		return strings.ToLower(children[i].Name) < strings.ToLower(children[j].Name)
+	})
+	return children
+}
+
+// Glob returns the nodes whose path matches pattern, sorted by lowercase
+// path. The syntax of the pattern is the one of path.Match, and a "**"
+// component matches any number of folders, including none. Like FindNode,
+// the matching is case insensitive and empty components are ignored. It
+// returns path.ErrBadPattern for an invalid pattern, or the error listing the
+// children of a folder in a lazy tree.
+func (nt *Tree) Glob(pattern string) ([]*Node, error) {
+	components := strings.Split(strings.ToLower(normalizePath(pattern)), "/")[1:]
+	if components[0] == "" {
+		components = nil
+	}
+	for _, component := range components {
+		if _, err := path.Match(component, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	g := &glob{tree: nt, matches: make(map[*Node]string), visited: make(map[globState]bool)}
+	g.match("/", nt.Node, components)
+	if g.err != nil {
+		return nil, g.err
+	}
+	nodes := make([]*Node, 0, len(g.matches))
+	for n := range g.matches {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return g.matches[nodes[i]] < g.matches[nodes[j]]
+	})
+	return nodes, nil
+}
+
+type (
+	// glob collects the nodes matching a pattern with their lowercase path.
+	glob struct {
+		tree    *Tree
+		matches map[*Node]string
+		// visited are the nodes already matched against the last components
+		// of the pattern, as with several "**" a node is reached many ways.
+		visited map[globState]bool
+		// err is the first error listing the children of a folder, which
+		// stops the matching.
+		err error
+	}
+
+	// globState is a node and the number of components of the pattern left
+	// to match under it.
+	globState struct {
+		n    *Node
+		left int
+	}
+)
+
+// match adds the nodes under n, n included, whose path relative to n, at p,
+// matches the components of a pattern.
+func (g *glob) match(p string, n *Node, components []string) {
+	state := globState{n: n, left: len(components)}
+	if g.visited[state] {
+		return
+	}
+	g.visited[state] = true
+	if g.err != nil {
+		return
+	}
+	if len(components) == 0 {
+		if _, ok := g.matches[n]; !ok {
+			g.matches[n] = p
+		}
+		return
+	}
+	component, rest := components[0], components[1:]
+	children, err := g.tree.childrenOf(n)
+	if err != nil {
+		g.err = err
+		return
+	}
+	for _, child := range children {
+		name := strings.ToLower(child.Name)
+		if component == "**" {
+			// the child is one of the folders matched by "**".
+			if child.IsDir() || len(rest) == 0 {
+				g.match(path.Join(p, name), child, components)
+			}
+			continue
+		}
+		if ok, _ := path.Match(component, name); ok {
+			g.match(path.Join(p, name), child, rest)
+		}
+	}
+	if component == "**" {
+		g.match(p, n, rest)
+	}
+}
@@ -0,0 +1,107 @@
+package node
+
+import (
+	"io/fs"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// newWalkTree returns a tree with the files and folders, folders ending with
+// a slash.
+func newWalkTree(paths ...string) *Tree {
+	root := &Node{Id: "/", IsRoot: true, Kind: KindFolder}
+	nt := &Tree{Node: root, nodeIdMap: map[string]*Node{"/": root}}
+	for _, p := range paths {
+		kind := KindFile
+		if p[len(p)-1] == '/' {
+			kind, p = KindFolder, p[:len(p)-1]
+		}
+		parent := nt.nodeIdMap[path.Dir(p)]
+		n := &Node{Id: p, Name: path.Base(p), Kind: kind, Parents: []string{parent.Id}}
+		nt.nodeIdMap[p] = n
+		nt.attach(parent, n)
+	}
+	return nt
+}
+
+func TestWalk(t *testing.T) {
+	nt := newWalkTree("/b/", "/b/z.txt", "/b/A.txt", "/a.txt", "/C/", "/C/d/", "/C/d/e.txt", "/C/f.txt")
+
+	walk := func(root string, skip string, skipErr error) ([]string, error) {
+		var got []string
+		err := nt.Walk(root, func(p string, n *Node, err error) error {
+			if err != nil {
+				return err
+			}
+			got = append(got, p)
+			if p == skip {
+				return skipErr
+			}
+			return nil
+		})
+		return got, err
+	}
+
+	tests := map[string]struct {
+		root, skip string
+		skipErr    error
+		want       []string
+	}{
+		"everything": {"/", "", nil, []string{"/", "/a.txt", "/b", "/b/A.txt", "/b/z.txt", "/C", "/C/d", "/C/d/e.txt", "/C/f.txt"}},
+		"sub folder": {"/c", "", nil, []string{"/C", "/C/d", "/C/d/e.txt", "/C/f.txt"}},
+		"skip dir":   {"/", "/b", fs.SkipDir, []string{"/", "/a.txt", "/b", "/C", "/C/d", "/C/d/e.txt", "/C/f.txt"}},
+		"skip file":  {"/", "/C/d/e.txt", fs.SkipDir, []string{"/", "/a.txt", "/b", "/b/A.txt", "/b/z.txt", "/C", "/C/d", "/C/d/e.txt", "/C/f.txt"}},
+		"skip rest":  {"/", "/b/A.txt", fs.SkipDir, []string{"/", "/a.txt", "/b", "/b/A.txt", "/C", "/C/d", "/C/d/e.txt", "/C/f.txt"}},
+		"skip all":   {"/", "/b", fs.SkipAll, []string{"/", "/a.txt", "/b"}},
+	}
+	for name, test := range tests {
+		got, err := walk(test.root, test.skip, test.skipErr)
+		if err != nil {
+			t.Errorf("%s: Walk() error: %s", name, err)
+		}
+		if !reflect.DeepEqual(test.want, got) {
+			t.Errorf("%s: Walk(): want %q got %q", name, test.want, got)
+		}
+	}
+
+	if _, err := walk("/missing", "", nil); err == nil {
+		t.Errorf("Walk() a missing root: want an error got nil")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	nt := newWalkTree("/photos/", "/photos/2023/", "/photos/2023/IMG_1.JPG", "/photos/2023/img_2.png",
+		"/photos/2024/", "/photos/2024/trip/", "/photos/2024/trip/IMG_3.jpg", "/photos/cover.jpg", "/notes.txt")
+
+	tests := map[string][]string{
+		"/":                       {"/"},
+		"/photos/*/img_?.jpg":     {"/photos/2023/IMG_1.JPG"},
+		"photos/202[34]":          {"/photos/2023", "/photos/2024"},
+		"/PHOTOS//**/*.jpg":       {"/photos/2023/IMG_1.JPG", "/photos/2024/trip/IMG_3.jpg", "/photos/cover.jpg"},
+		"**/trip":                 {"/photos/2024/trip"},
+		"/photos/2024/**":         {"/photos/2024", "/photos/2024/trip", "/photos/2024/trip/IMG_3.jpg"},
+		"/**/**/*.txt":            {"/notes.txt"},
+		"/**/**/**/trip/**/*.jpg": {"/photos/2024/trip/IMG_3.jpg"},
+		"/photos/[^2]*":           {"/photos/cover.jpg"},
+		"/photos/2023/*.jpg/foo":  nil,
+	}
+	for pattern, want := range tests {
+		nodes, err := nt.Glob(pattern)
+		if err != nil {
+			t.Errorf("Glob(%q) error: %s", pattern, err)
+			continue
+		}
+		var got []string
+		for _, n := range nodes {
+			got = append(got, n.Id)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Glob(%q): want %q got %q", pattern, want, got)
+		}
+	}
+
+	if _, err := nt.Glob("/photos/[a-"); err != path.ErrBadPattern {
+		t.Errorf("Glob() a bad pattern: want %s got %v", path.ErrBadPattern, err)
+	}
+}